@@ -87,16 +87,291 @@ type (
 	Ringpop struct {
 		// Name to be used in ringpop advertisement
 		Name string `yaml:"name" validate:"nonzero"`
+		// AppName is the app name ringpop uses to scope its membership, defaults to Name
+		AppName string `yaml:"appName"`
+		// NamePrefix, when set, namespaces the ring of a logical cluster on
+		// shared infrastructure. It is prepended to AppName for ringpop, and
+		// members whose prefixed app name differs are rejected, so that rings
+		// of distinct prefixes never cross join
+		NamePrefix string `yaml:"namePrefix"`
+		// NameCasing is how the casing of Name and AppName is handled, one of
+		// `preserve` (default), `lower` to lowercase them or `strict` to reject
+		// names that aren't lowercase. Strict also holds Name to the charset of
+		// AppName, letters, digits, `_`, `.` and `-`
+		NameCasing string `yaml:"nameCasing"`
 		// BootstrapMode is a enum that defines the ringpop bootstrap method
 		BootstrapMode BootstrapMode `yaml:"bootstrapMode"`
-		// BootstrapHosts is a list of seed hosts to be used for ringpop bootstrap
+		// BootstrapHosts is a list of seed hosts to be used for ringpop bootstrap,
+		// with the dns bootstrap mode these are hostname:port to be resolved and
+		// with the dnssrv bootstrap mode these are SRV names to be resolved
 		BootstrapHosts []string `yaml:"bootstrapHosts"`
-		// BootstrapFile is the file path to be used for ringpop bootstrap
+		// HonorSRVPriority orders the targets of the SRV names of the dnssrv
+		// bootstrap mode by priority and weight, as in RFC 2782, instead of
+		// by name. Ringpop prefers to join the seeds that come first
+		HonorSRVPriority bool `yaml:"honorSRVPriority"`
+		// BootstrapFile is the file path to be used for ringpop bootstrap, by the
+		// file bootstrap mode and by the mmap bootstrap mode that memory maps it
+		// for the colocated processes of a dev or test cluster
 		BootstrapFile string `yaml:"bootstrapFile"`
+		// BootstrapFileFormat is the format of the bootstrap file, either json
+		// (the default) for a json list of seeds, or jsonl for one seed per line
+		BootstrapFileFormat string `yaml:"bootstrapFileFormat"`
+		// BootstrapFileStaleness is how long the bootstrap file may stay unchanged
+		// across reads before it is considered stale, like when served from an
+		// nfs cache, and read again. Zero disables the staleness check
+		BootstrapFileStaleness time.Duration `yaml:"bootstrapFileStaleness"`
+		// BootstrapS3Bucket is the s3 bucket holding the seed list for the s3 bootstrap mode
+		BootstrapS3Bucket string `yaml:"bootstrapS3Bucket"`
+		// BootstrapS3Key is the key of the s3 object holding the seed list,
+		// parsed per BootstrapFileFormat
+		BootstrapS3Key string `yaml:"bootstrapS3Key"`
+		// BootstrapS3Region is the aws region of the bootstrap s3 bucket,
+		// defaults to the region of the default aws configuration
+		BootstrapS3Region string `yaml:"bootstrapS3Region"`
+		// BootstrapNomadAddress is the nomad agent address for the nomad bootstrap
+		// mode, defaults to NOMAD_ADDR or to http://127.0.0.1:4646
+		BootstrapNomadAddress string `yaml:"bootstrapNomadAddress"`
+		// BootstrapNomadJob is the nomad job whose running allocations are the seeds
+		BootstrapNomadJob string `yaml:"bootstrapNomadJob"`
+		// BootstrapNomadPort is the ringpop port of the allocations, either
+		// a port number or the label of a port of the job's network
+		BootstrapNomadPort string `yaml:"bootstrapNomadPort"`
+		// BootstrapZKConnect is the comma separated list of zookeeper
+		// servers for the zk bootstrap mode
+		BootstrapZKConnect string `yaml:"bootstrapZKConnect"`
+		// BootstrapZKPath is the znode listing the seeds, whose children
+		// are the host:port of the seeds
+		BootstrapZKPath string `yaml:"bootstrapZKPath"`
+		// BootstrapZKReadData reads the seeds from the data of the znode,
+		// in the bootstrap file format, instead of its children
+		BootstrapZKReadData bool `yaml:"bootstrapZKReadData"`
+		// BootstrapK8sNamespace and BootstrapK8sSelector select the pods listed
+		// by the k8spods bootstrap mode, whose ips are joined on BootstrapK8sPort
+		BootstrapK8sNamespace string `yaml:"bootstrapK8sNamespace"`
+		BootstrapK8sSelector  string `yaml:"bootstrapK8sSelector"`
+		BootstrapK8sPort      int    `yaml:"bootstrapK8sPort"`
+		// BootstrapEtcdEndpoints are the http urls of the etcd servers for the
+		// etcd bootstrap mode, which joins the values of the keys starting with
+		// BootstrapEtcdPrefix. With BootstrapEtcdRequireLease, only the keys
+		// attached to a live lease are joined, so that dead nodes are left out
+		BootstrapEtcdEndpoints    []string `yaml:"bootstrapEtcdEndpoints"`
+		BootstrapEtcdPrefix       string   `yaml:"bootstrapEtcdPrefix"`
+		BootstrapEtcdRequireLease bool     `yaml:"bootstrapEtcdRequireLease"`
+		// BootstrapSystemdCredential is the name of the systemd credential
+		// holding the seeds for the systemdcreds bootstrap mode. It is read
+		// from $CREDENTIALS_DIRECTORY and parsed per BootstrapFileFormat
+		BootstrapSystemdCredential string `yaml:"bootstrapSystemdCredential"`
+		// BootstrapMDNSService is the service type, like _cadence-ringpop._tcp,
+		// advertised and browsed with multicast dns by the mdns bootstrap mode.
+		// It is meant for isolated local networks only
+		BootstrapMDNSService string `yaml:"bootstrapMDNSService"`
+		// BootstrapPeer is the host:port of a ring member whose view of the
+		// membership is used as the seeds for the peer bootstrap mode
+		BootstrapPeer string `yaml:"bootstrapPeer"`
+		// BootstrapFrontendAddress is the tchannel host:port of a cadence frontend
+		// for the frontend bootstrap mode, which joins the members of the
+		// BootstrapFrontendService service, like cadence-history, as seen by the
+		// ringpop of that frontend
+		BootstrapFrontendAddress string `yaml:"bootstrapFrontendAddress"`
+		BootstrapFrontendService string `yaml:"bootstrapFrontendService"`
+		// BootstrapVaultAddress is the url of the vault server, or of a vault
+		// agent, for the vault bootstrap mode. The seeds are read from the
+		// BootstrapVaultField field of the kv secret at BootstrapVaultPath, like
+		// secret/data/cadence/ringpop, and parsed per BootstrapFileFormat. The
+		// token is taken from $VAULT_TOKEN, or added by the vault agent
+		BootstrapVaultAddress string `yaml:"bootstrapVaultAddress"`
+		BootstrapVaultPath    string `yaml:"bootstrapVaultPath"`
+		BootstrapVaultField   string `yaml:"bootstrapVaultField"`
+		// BootstrapHostDenyList is a list of host:port that are never used as
+		// seeds, whatever the bootstrap mode. Hostnames deny the addresses they
+		// resolve to
+		BootstrapHostDenyList []string `yaml:"bootstrapHostDenyList"`
 		// MaxJoinDuration is the max wait time to join the ring
 		MaxJoinDuration time.Duration `yaml:"maxJoinDuration"`
+		// DiscoveryInitialDelay is how long the first bootstrap may wait for the
+		// discovered seeds to settle, for dynamic sources where peers register
+		// as they start. Seeds are polled until two polls in a row return the
+		// same non zero count, or the delay elapses. Zero disables the delay
+		DiscoveryInitialDelay time.Duration `yaml:"discoveryInitialDelay"`
+		// BootstrapWarmup is how long the member count must stop growing after
+		// a successful join before the ring is handed out, zero disables warmup
+		BootstrapWarmup time.Duration `yaml:"bootstrapWarmup"`
+		// BootstrapWarmupMax caps the warmup, defaults to MaxJoinDuration
+		BootstrapWarmupMax time.Duration `yaml:"bootstrapWarmupMax"`
+		// BootstrapProgressInterval is the interval at which the progress of a
+		// bootstrap still in flight is logged, zero disables the progress logs
+		BootstrapProgressInterval time.Duration `yaml:"bootstrapProgressInterval"`
+		// BlendDynamicMode is the dynamic source of the blend bootstrap mode,
+		// whose hosts are appended to the seeds of BootstrapFile. It is
+		// configured by the same params as the bootstrap mode of that name
+		BlendDynamicMode BootstrapMode `yaml:"blendDynamicMode"`
+		// MaxDynamicSeeds caps the number of hosts of the dynamic source
+		// added by the blend bootstrap mode, zero means no cap
+		MaxDynamicSeeds int `yaml:"maxDynamicSeeds"`
+		// CompositeMinSuccessfulSources is the min number of sources that must return
+		// hosts for the composite bootstrap mode to succeed, defaults to 1
+		CompositeMinSuccessfulSources int `yaml:"compositeMinSuccessfulSources"`
+		// AddressNormalization is how discovered IP addresses are canonicalized before
+		// being deduplicated, one of `ipv4`, `ipv6` or `none` (default)
+		AddressNormalization string `yaml:"addressNormalization"`
+		// FirstNode tells that this node may legitimately be the only member of the
+		// ring after bootstrapping, like the first node of a new cluster
+		FirstNode bool `yaml:"firstNode"`
+		// SelfOnlyBootstrapPolicy is what to do when this node ends up being the only
+		// member of the ring after bootstrapping and it isn't the first node, one of
+		// `warn` (default) or `fail`
+		SelfOnlyBootstrapPolicy string `yaml:"selfOnlyBootstrapPolicy"`
+		// AdvertiseAddress is the ip or ip:port this node advertises to the ring,
+		// defaults to the tchannel address, or to the first routable interface
+		// address when tchannel listens on a wildcard address
+		AdvertiseAddress string `yaml:"advertiseAddress"`
+		// AdvertisePort is the port this node advertises to the ring when it
+		// differs from the tchannel port, like behind a port remapping
+		AdvertisePort int `yaml:"advertisePort"`
+		// DisableFaultyReaping keeps faulty members in the membership for a week
+		// instead of reaping them after the day swim defaults to, so that a node
+		// declared faulty during a pause can refute it and rejoin without a
+		// restart. The membership grows by the members gone faulty in that week
+		DisableFaultyReaping bool `yaml:"disableFaultyReaping"`
+		// SuspicionTimeoutBase and SuspicionTimeoutPerMember, when set, size the
+		// swim suspicion timeout to the ring as base + per member increment,
+		// capped at SuspicionTimeoutMax when that is set. The member count is
+		// the number of seeds when the ring is joined
+		SuspicionTimeoutBase      time.Duration `yaml:"suspicionTimeoutBase"`
+		SuspicionTimeoutPerMember time.Duration `yaml:"suspicionTimeoutPerMember"`
+		SuspicionTimeoutMax       time.Duration `yaml:"suspicionTimeoutMax"`
+		// RingpopRequestTimeout is the timeout of the join requests sent to the
+		// seeds, zero keeps the swim default of 1s. Lengthen it for rings over
+		// high latency links. The pings and ping requests of ringpop keep their
+		// own timeouts, which aren't configurable, and a member is suspected
+		// when they fail. Keep the suspicion timeout well above the ping timeouts
+		// so that a slow member has the time to refute its suspicion
+		RingpopRequestTimeout time.Duration `yaml:"ringpopRequestTimeout"`
+		// MetricsSampleInterval is the interval at which the members gauge is
+		// emitted, zero emits it on every change of the ring. The counters of
+		// the members joining and leaving are always emitted on every change
+		MetricsSampleInterval time.Duration `yaml:"metricsSampleInterval"`
+		// MetricTags are static tags, like ring: history, added to every metric
+		// the factory emits and to the fields of every log it writes, so that
+		// the rings of a process running several of them can be told apart
+		MetricTags map[string]string `yaml:"metricTags"`
+		// HashRingReplicaPoints is the number of points of each member on the
+		// hash ring, zero keeps the ringpop default. More points distribute the
+		// keys more evenly, at the cost of memory and of the cpu spent updating
+		// the ring on every membership change
+		HashRingReplicaPoints int `yaml:"hashRingReplicaPoints"`
+		// TombstonePeriod is how long a member that left the ring is gossiped
+		// as a tombstone before being removed from the membership, zero keeps
+		// the ringpop default. A shorter period takes departed members out of
+		// the gossip and of the full syncs sooner
+		TombstonePeriod time.Duration `yaml:"tombstonePeriod"`
+		// LabelLimitCount and LabelLimitValueSize cap the number of labels of a
+		// member and the size of their values, zero keeps the ringpop defaults.
+		// Labels are gossiped with the membership, lower limits keep the gossip
+		// small, but they must fit the partition and maintenance labels
+		LabelLimitCount     int `yaml:"labelLimitCount"`
+		LabelLimitValueSize int `yaml:"labelLimitValueSize"`
+		// PortAutoIncrement is a development convenience for colocated nodes.
+		// When ringpop has its own channel and its port is taken, the next
+		// free port among the following PortAutoIncrement ones is used
+		PortAutoIncrement int `yaml:"portAutoIncrement"`
+		// Partition, when set, is the partition label of this node, see
+		// RingpopFactory.MembersInPartition for the partition-aware view
+		Partition string `yaml:"partition"`
+		// RequiredMembers is a list of host:port that must all be members
+		// of the ring for this node to report itself as ready
+		RequiredMembers []string `yaml:"requiredMembers"`
+		// FilterNonRoutableSeeds drops the loopback, link-local and
+		// unspecified addresses from the discovered seeds
+		FilterNonRoutableSeeds bool `yaml:"filterNonRoutableSeeds"`
+		// DerivePortFromChannel appends the port the ringpop channel listens on
+		// to the discovered seeds without a port, for rings where every node
+		// gossips on the same port. The channel must not listen on port 0
+		DerivePortFromChannel bool `yaml:"derivePortFromChannel"`
+		// SeedSnapshotFile, when set, is where the members of the ring are
+		// written every SeedSnapshotInterval (defaults to 1m). Discovery falls
+		// back to it when the bootstrap source fails or returns fewer than
+		// SeedSnapshotMinHosts hosts (defaults to 1)
+		SeedSnapshotFile     string        `yaml:"seedSnapshotFile"`
+		SeedSnapshotInterval time.Duration `yaml:"seedSnapshotInterval"`
+		SeedSnapshotMinHosts int           `yaml:"seedSnapshotMinHosts"`
+		// DetectDuplicateName fails the creation of ringpop when another
+		// factory of this process already created one with the same name.
+		// The detection is per process, it doesn't see other processes
+		DetectDuplicateName bool `yaml:"detectDuplicateName"`
+		// LeaveGracePeriod is how long Destroy waits after leaving the ring
+		// for the leave to reach the peers before ringpop is torn down,
+		// defaults to 1s
+		LeaveGracePeriod time.Duration `yaml:"leaveGracePeriod"`
+		// MembershipPollInterval is the interval at which WaitForMembership
+		// polls the members of the ring, defaults to 1s
+		MembershipPollInterval time.Duration `yaml:"membershipPollInterval"`
+		// ReadinessDebounce is how long required members must stay missing before
+		// a ready node reports itself as not ready, recovery is reported immediately
+		ReadinessDebounce time.Duration `yaml:"readinessDebounce"`
+		// MembershipCrossDebounce is how long the member count must stay past
+		// a threshold registered with OnMembershipCross before its callback is
+		// called, zero calls it on the ring change that crosses the threshold
+		MembershipCrossDebounce time.Duration `yaml:"membershipCrossDebounce"`
+		// ReconcileInterval is the interval at which the membership is compared
+		// against a freshly discovered seed list, zero disables reconciliation
+		ReconcileInterval time.Duration `yaml:"reconcileInterval"`
+		// MaxMembers is the max number of members of the ring, checked after joining
+		// and then at every MaxMembersCheckInterval, zero disables the check
+		MaxMembers int `yaml:"maxMembers"`
+		// MaxMembersPolicy is what to do when the ring exceeds MaxMembers, one of
+		// `warn` (default) or `leave` to leave the ring, after which Ready reports
+		// a *MaxMembersError
+		MaxMembersPolicy string `yaml:"maxMembersPolicy"`
+		// MaxMembersCheckInterval is the interval at which the member count is
+		// checked against MaxMembers once joined, defaults to 30s
+		MaxMembersCheckInterval time.Duration `yaml:"maxMembersCheckInterval"`
+		// DiscoveryQPS is the max rate of seed discovery calls made by this node,
+		// zero disables rate limiting
+		DiscoveryQPS float64 `yaml:"discoveryQPS"`
+		// DiscoveryBurst is the max burst of seed discovery calls, defaults to 1
+		DiscoveryBurst int `yaml:"discoveryBurst"`
+		// DiscoveryBreakerThreshold is the number of consecutive discovery failures
+		// after which the last good seeds are served for DiscoveryBreakerCooldown
+		// (defaults to 30s) before discovery is probed again, zero disables it
+		DiscoveryBreakerThreshold int           `yaml:"discoveryBreakerThreshold"`
+		DiscoveryBreakerCooldown  time.Duration `yaml:"discoveryBreakerCooldown"`
+		// DiscoveryRefreshMaxInterval is the max interval the reconciliation backs off
+		// to while seed discovery keeps failing, zero keeps the reconcile interval
+		DiscoveryRefreshMaxInterval time.Duration `yaml:"discoveryRefreshMaxInterval"`
+		// ReconcileMinOverlap is the min fraction of discovered seeds that must be
+		// members of the ring before reconciliation rejoins them, defaults to 0.5
+		ReconcileMinOverlap float64 `yaml:"reconcileMinOverlap"`
+		// BootstrapRetryAttempts is the number of times a failed bootstrap is retried,
+		// zero disables retries unless BootstrapRetryMaxElapsed is set. Permanent
+		// failures, like a non existent dns name, are never retried
+		BootstrapRetryAttempts int `yaml:"bootstrapRetryAttempts"`
+		// BootstrapRetryInterval is the initial backoff between bootstrap retries
+		BootstrapRetryInterval time.Duration `yaml:"bootstrapRetryInterval"`
+		// BootstrapRetryMaxInterval caps the backoff between bootstrap retries,
+		// defaults to 10s
+		BootstrapRetryMaxInterval time.Duration `yaml:"bootstrapRetryMaxInterval"`
+		// BootstrapRetryMaxElapsed bounds the total time spent retrying the
+		// bootstrap, whatever the number of attempts left. Zero means no bound
+		BootstrapRetryMaxElapsed time.Duration `yaml:"bootstrapRetryMaxElapsed"`
+		// SelfOnlyAbortThreshold aborts the bootstrap retries once this many
+		// consecutive attempts resolve seeds that are nothing but this node, which
+		// hints at a misconfigured discovery rather than peers still starting,
+		// like when selfOnlyBootstrapPolicy fails such bootstraps. Ignored for
+		// the first node, zero disables the abort
+		SelfOnlyAbortThreshold int `yaml:"selfOnlyAbortThreshold"`
 		// Custom discovery provider, cannot be specified through yaml
 		DiscoveryProvider discovery.DiscoverProvider `yaml:"-"`
+		// Resolver resolves the names of the dns and dnssrv bootstrap modes and
+		// of the deny list, defaults to net.DefaultResolver. It cannot be
+		// specified through yaml
+		Resolver Resolver `yaml:"-"`
+		// DNSForceFreshOnRetry makes the bootstrap attempts following a failed
+		// one resolve the dns names with the pure go resolver, which queries the
+		// name servers directly instead of going through libc and the caching
+		// daemons, like nscd, that may serve a stale record. It doesn't apply
+		// to a custom Resolver
+		DNSForceFreshOnRetry bool `yaml:"dnsForceFreshOnRetry"`
 	}
 
 	// Persistence contains the configuration for data store / persistence layer
@@ -21,20 +21,27 @@
 package config
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net"
 	"reflect"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/sirupsen/logrus"
+	"github.com/uber-common/bark"
+	"github.com/uber-go/tally"
 	"github.com/uber/ringpop-go"
 	"github.com/uber/ringpop-go/discovery"
-	"github.com/uber/ringpop-go/discovery/jsonfile"
-	"github.com/uber/ringpop-go/discovery/statichosts"
+	"github.com/uber/ringpop-go/hashring"
 	"github.com/uber/ringpop-go/swim"
 	tcg "github.com/uber/tchannel-go"
 	"go.uber.org/yarpc"
 	"go.uber.org/yarpc/transport/tchannel"
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -46,28 +53,389 @@ const (
 	BootstrapModeHosts
 	// BootstrapModeCustom represents a custom bootstrap mode
 	BootstrapModeCustom
+	// BootstrapModeComposite represents a bootstrap mode that merges
+	// the bootstrap sources that are configured
+	BootstrapModeComposite
+	// BootstrapModeDNS represents a list of hostname:port passed in
+	// the configuration that are resolved through dns
+	BootstrapModeDNS
+	// BootstrapModeS3 represents a seed list read from an s3 object
+	BootstrapModeS3
+	// BootstrapModeNomad represents the allocations of a nomad job
+	BootstrapModeNomad
+	// BootstrapModePeer represents the members of the ring
+	// as seen by a coordinator member
+	BootstrapModePeer
+	// BootstrapModeZK represents the children or the data of a zookeeper znode
+	BootstrapModeZK
+	// BootstrapModeK8sPods represents the running and ready
+	// kubernetes pods matching a label selector
+	BootstrapModeK8sPods
+	// BootstrapModeMDNS represents the instances of a service
+	// type found with multicast dns on the local network
+	BootstrapModeMDNS
+	// BootstrapModeEtcd represents the values of the keys under an etcd prefix
+	BootstrapModeEtcd
+	// BootstrapModeDNSSRV represents a list of dns SRV names passed
+	// in the configuration that are resolved into their targets
+	BootstrapModeDNSSRV
+	// BootstrapModeSystemdCreds represents the seeds delivered as a
+	// systemd credential of the unit
+	BootstrapModeSystemdCreds
+	// BootstrapModeBlend represents the bootstrap file seeds followed
+	// by the hosts of a dynamic source that the file doesn't list
+	BootstrapModeBlend
+	// BootstrapModeMmap represents a memory mapped bootstrap file shared
+	// by colocated processes, meant for dev and test clusters
+	BootstrapModeMmap
+	// BootstrapModeFrontend represents the members of a cadence
+	// service as seen by a cadence frontend
+	BootstrapModeFrontend
+	// BootstrapModeVault represents a seed list stored
+	// in a field of a vault kv secret
+	BootstrapModeVault
 )
 
 const (
-	defaultMaxJoinDuration = 10 * time.Second
+	// swimJoinTimeout and swimJoinParallelism mirror the swim defaults
+	// for the per seed join timeout and the number of seeds joined at once
+	swimJoinTimeout     = time.Second
+	swimJoinParallelism = 2
 )
 
+// unreapedFaultyPeriod is how long swim keeps a faulty member when faulty
+// reaping is disabled, a week against the day swim defaults to. It stays
+// finite so that the membership doesn't grow without bound
+const unreapedFaultyPeriod = 7 * 24 * time.Hour
+
+const (
+	nameCasingPreserve = "preserve"
+	nameCasingLower    = "lower"
+	nameCasingStrict   = "strict"
+)
+
+const (
+	defaultCompositeMinSuccessfulSources = 1
+	defaultReconcileMinOverlap           = 0.5
+	defaultBootstrapRetryInterval        = time.Second
+	defaultDiscoveryBurst                = 1
+	defaultDiscoveryBreakerCooldown      = 30 * time.Second
+	defaultMembershipPollInterval        = time.Second
+	maxPortAutoIncrement                 = 100
+	defaultLeaveGracePeriod              = time.Second
+	defaultSeedSnapshotInterval          = time.Minute
+	defaultMaxMembersCheckInterval       = 30 * time.Second
+)
+
+// defaultMaxJoinDuration is the max join duration used when the
+// config leaves it unset, it can be changed with SetDefaultMaxJoinDuration
+var defaultMaxJoinDuration = struct {
+	sync.RWMutex
+	value time.Duration
+}{value: 10 * time.Second}
+
+// SetDefaultMaxJoinDuration changes the max join duration used by
+// the factories created afterwards when their config leaves it unset
+func SetDefaultMaxJoinDuration(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("ringpop default max join duration must be positive, got %v", d)
+	}
+	defaultMaxJoinDuration.Lock()
+	defer defaultMaxJoinDuration.Unlock()
+	defaultMaxJoinDuration.value = d
+	return nil
+}
+
+func getDefaultMaxJoinDuration() time.Duration {
+	defaultMaxJoinDuration.RLock()
+	defer defaultMaxJoinDuration.RUnlock()
+	return defaultMaxJoinDuration.value
+}
+
+// validRingpopName is the charset accepted for ringpop names
+var validRingpopName = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// Factory is the subset of RingpopFactory that services depend on,
+// so that it can be mocked. It is kept to creating the ring, reading
+// its membership and tearing it down, the other methods of
+// RingpopFactory are only needed by the code that sets it up
+type Factory interface {
+	// CreateRingpop creates ringpop and joins the ring
+	CreateRingpop(dispatcher *yarpc.Dispatcher) (*ringpop.Ringpop, error)
+	// CreateRingpopWithJoinDuration is like CreateRingpop with another max join duration
+	CreateRingpopWithJoinDuration(dispatcher *yarpc.Dispatcher, maxJoinDuration time.Duration) (*ringpop.Ringpop, error)
+	// Ready returns an error when this node isn't ready to serve
+	Ready() error
+	// Members returns the reachable members of the ring
+	Members(opts ...MembersOption) ([]string, error)
+	// IsMember returns true when addr is a reachable member of the ring
+	IsMember(addr string) (bool, error)
+	// Lookup returns the address of the node owning key on the hash ring
+	Lookup(key string) (string, error)
+	// LookupN returns the addresses of the n nodes owning key on the hash ring
+	LookupN(key string, n int) ([]string, error)
+	// Destroy leaves the ring and releases the resources of the factory
+	Destroy()
+}
+
+var _ Factory = (*RingpopFactory)(nil)
+
 // RingpopFactory implements the RingpopFactory interface
 type RingpopFactory struct {
-	config *Ringpop
+	// discoveryAttempts is accessed atomically and
+	// kept first for 64-bit alignment
+	discoveryAttempts   uint64
+	config              *Ringpop
+	logger              bark.Logger
+	metricsScope        tally.Scope
+	provider            discovery.DiscoverProvider
+	clock               Clock
+	discoveryLimiter    *rate.Limiter
+	discoveryBreaker    *discoveryBreaker
+	bootstrapMode       BootstrapMode
+	addressTransform    AddressTransform
+	seedScorer          SeedScorer
+	seedListTransform   SeedListTransform
+	seedValidator       SeedValidator
+	ringpopOptionsExtra []ringpop.Option
+	tracer              Tracer
+	fileStaleness       fileStaleness
+	channel             *tcg.Channel
+	ownChannel          *tcg.Channel
+	ringpop             *ringpop.Ringpop
+	mutex               sync.Mutex
+	wasReady            bool
+	unreadySince        time.Time
+	maintenance         bool
+	nameRegistered      bool
+	mdnsResponder       *mdnsResponder
+	ringChangeHandlers  []RingChangeHandler
+	membershipCrossings []*membershipCrossing
+	left                bool
+	maxMembersErr       error
+	freshDNS            bool
+	swimCall            swimCaller
+	attemptID           string
+	lastAttemptID       string
+	stopOnce            sync.Once
+	shutdownCh          chan struct{}
+	shutdownWG          sync.WaitGroup
 }
 
 // NewFactory builds a ringpop factory conforming
 // to the underlying configuration
 func (rpConfig *Ringpop) NewFactory() (*RingpopFactory, error) {
-	return newRingpopFactory(rpConfig)
+	return NewFactory(rpConfig)
 }
 
-func (rpConfig *Ringpop) validate() error {
+// NewFactory builds a ringpop factory conforming to the
+// given configuration and customized by the given options
+func NewFactory(rpConfig *Ringpop, opts ...FactoryOption) (*RingpopFactory, error) {
+	return newRingpopFactory(rpConfig, opts...)
+}
+
+// ValidateAll validates the ringpop config and returns every failure found,
+// so that all problems of a config can be reported at once
+func (rpConfig *Ringpop) ValidateAll() []error {
+	var errs []error
 	if len(rpConfig.Name) == 0 {
-		return fmt.Errorf("ringpop config missing `name` param")
+		errs = append(errs, fmt.Errorf("ringpop config missing `name` param"))
+	} else if strings.ToLower(rpConfig.NameCasing) == nameCasingStrict {
+		// name predates the charset, which is only enforced on it when strict
+		if err := validateRingpopName("name", rpConfig.Name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(rpConfig.AppName) > 0 {
+		if err := validateRingpopName("appName", rpConfig.AppName); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(rpConfig.NamePrefix) > 0 {
+		if err := validateRingpopName("namePrefix", rpConfig.NamePrefix); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := validateNameCasing(rpConfig); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateBootstrapFileFormat(rpConfig.BootstrapFileFormat); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateAddressNormalization(rpConfig.AddressNormalization); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateMetricTags(rpConfig.MetricTags); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateAdvertiseAddress(rpConfig.AdvertiseAddress, rpConfig.AdvertisePort); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateAdvertiseConflicts(rpConfig); err != nil {
+		errs = append(errs, err)
+	}
+	switch strings.ToLower(rpConfig.SelfOnlyBootstrapPolicy) {
+	case "", selfOnlyBootstrapPolicyWarn, selfOnlyBootstrapPolicyFail:
+	default:
+		errs = append(errs, fmt.Errorf("ringpop config `selfOnlyBootstrapPolicy` must be one of %v or %v", selfOnlyBootstrapPolicyWarn, selfOnlyBootstrapPolicyFail))
+	}
+	if rpConfig.BootstrapRetryAttempts < 0 || rpConfig.BootstrapRetryInterval < 0 {
+		errs = append(errs, fmt.Errorf("ringpop config `bootstrapRetryAttempts` and `bootstrapRetryInterval` must not be negative"))
+	}
+	if rpConfig.BootstrapRetryMaxInterval < 0 || rpConfig.BootstrapRetryMaxElapsed < 0 {
+		errs = append(errs, fmt.Errorf("ringpop config `bootstrapRetryMaxInterval` and `bootstrapRetryMaxElapsed` must not be negative"))
+	} else if rpConfig.BootstrapRetryMaxElapsed > 0 && rpConfig.BootstrapRetryMaxInterval > rpConfig.BootstrapRetryMaxElapsed {
+		errs = append(errs, fmt.Errorf("ringpop config `bootstrapRetryMaxInterval` must not exceed `bootstrapRetryMaxElapsed`"))
+	}
+	if rpConfig.SelfOnlyAbortThreshold < 0 {
+		errs = append(errs, fmt.Errorf("ringpop config `selfOnlyAbortThreshold` must not be negative"))
+	}
+	if rpConfig.BootstrapProgressInterval < 0 {
+		errs = append(errs, fmt.Errorf("ringpop config `bootstrapProgressInterval` must not be negative"))
+	}
+	if rpConfig.BootstrapWarmup < 0 || rpConfig.BootstrapWarmupMax < 0 {
+		errs = append(errs, fmt.Errorf("ringpop config `bootstrapWarmup` and `bootstrapWarmupMax` must not be negative"))
+	}
+	if rpConfig.SeedSnapshotInterval < 0 || rpConfig.SeedSnapshotMinHosts < 0 {
+		errs = append(errs, fmt.Errorf("ringpop config `seedSnapshotInterval` and `seedSnapshotMinHosts` must not be negative"))
+	}
+	maxJoinDuration := rpConfig.MaxJoinDuration
+	if maxJoinDuration == 0 {
+		maxJoinDuration = getDefaultMaxJoinDuration()
+	}
+	if rpConfig.DiscoveryInitialDelay < 0 || rpConfig.DiscoveryInitialDelay > maxJoinDuration {
+		errs = append(errs, fmt.Errorf("ringpop config `discoveryInitialDelay` must be between 0 and `maxJoinDuration` (%v)", maxJoinDuration))
+	}
+	if rpConfig.LeaveGracePeriod < 0 {
+		errs = append(errs, fmt.Errorf("ringpop config `leaveGracePeriod` must not be negative"))
+	}
+	if rpConfig.MembershipPollInterval < 0 {
+		errs = append(errs, fmt.Errorf("ringpop config `membershipPollInterval` must not be negative"))
+	}
+	if rpConfig.ReadinessDebounce < 0 {
+		errs = append(errs, fmt.Errorf("ringpop config `readinessDebounce` must not be negative"))
+	}
+	if rpConfig.MembershipCrossDebounce < 0 {
+		errs = append(errs, fmt.Errorf("ringpop config `membershipCrossDebounce` must not be negative"))
+	}
+	if rpConfig.ReconcileInterval < 0 {
+		errs = append(errs, fmt.Errorf("ringpop config `reconcileInterval` must not be negative"))
+	}
+	if rpConfig.SuspicionTimeoutBase < 0 || rpConfig.SuspicionTimeoutPerMember < 0 || rpConfig.SuspicionTimeoutMax < 0 {
+		errs = append(errs, fmt.Errorf("ringpop config `suspicionTimeoutBase`, `suspicionTimeoutPerMember` and `suspicionTimeoutMax` must not be negative"))
+	}
+	if rpConfig.RingpopRequestTimeout < 0 {
+		errs = append(errs, fmt.Errorf("ringpop config `ringpopRequestTimeout` must not be negative"))
+	}
+	if rpConfig.SuspicionTimeoutMax > 0 && rpConfig.SuspicionTimeoutMax < rpConfig.SuspicionTimeoutBase {
+		errs = append(errs, fmt.Errorf("ringpop config `suspicionTimeoutMax` must not be less than `suspicionTimeoutBase`"))
+	}
+	if err := validateLabelLimits(rpConfig); err != nil {
+		errs = append(errs, err)
+	}
+	if rpConfig.MetricsSampleInterval < 0 {
+		errs = append(errs, fmt.Errorf("ringpop config `metricsSampleInterval` must not be negative"))
+	}
+	if rpConfig.HashRingReplicaPoints < 0 {
+		errs = append(errs, fmt.Errorf("ringpop config `hashRingReplicaPoints` must not be negative"))
+	}
+	if rpConfig.MaxMembers < 0 {
+		errs = append(errs, fmt.Errorf("ringpop config `maxMembers` must not be negative"))
+	}
+	if rpConfig.MaxMembersCheckInterval < 0 {
+		errs = append(errs, fmt.Errorf("ringpop config `maxMembersCheckInterval` must not be negative"))
+	}
+	switch strings.ToLower(rpConfig.MaxMembersPolicy) {
+	case "", maxMembersPolicyWarn, maxMembersPolicyLeave:
+	default:
+		errs = append(errs, fmt.Errorf("ringpop config `maxMembersPolicy` must be one of %v or %v", maxMembersPolicyWarn, maxMembersPolicyLeave))
+	}
+	if rpConfig.BootstrapFileStaleness < 0 {
+		errs = append(errs, fmt.Errorf("ringpop config `bootstrapFileStaleness` must not be negative"))
+	}
+	if rpConfig.DiscoveryRefreshMaxInterval < 0 {
+		errs = append(errs, fmt.Errorf("ringpop config `discoveryRefreshMaxInterval` must not be negative"))
+	}
+	if rpConfig.DiscoveryBreakerThreshold < 0 || rpConfig.DiscoveryBreakerCooldown < 0 {
+		errs = append(errs, fmt.Errorf("ringpop config `discoveryBreakerThreshold` and `discoveryBreakerCooldown` must not be negative"))
+	}
+	if rpConfig.DiscoveryQPS < 0 || rpConfig.DiscoveryBurst < 0 {
+		errs = append(errs, fmt.Errorf("ringpop config `discoveryQPS` and `discoveryBurst` must not be negative"))
 	}
-	return validateBootstrapMode(rpConfig)
+	if rpConfig.ReconcileMinOverlap < 0 || rpConfig.ReconcileMinOverlap > 1 {
+		errs = append(errs, fmt.Errorf("ringpop config `reconcileMinOverlap` must be between 0 and 1"))
+	}
+	for _, host := range rpConfig.BootstrapHostDenyList {
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			errs = append(errs, fmt.Errorf("ringpop config `bootstrapHostDenyList` contains invalid host:port %q", host))
+		}
+	}
+	if rpConfig.PortAutoIncrement < 0 || rpConfig.PortAutoIncrement > maxPortAutoIncrement {
+		errs = append(errs, fmt.Errorf("ringpop config `portAutoIncrement` must be between 0 and %v", maxPortAutoIncrement))
+	}
+	if len(rpConfig.Partition) > 0 {
+		if err := validatePartition(rpConfig.Partition); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, member := range rpConfig.RequiredMembers {
+		if _, _, err := net.SplitHostPort(member); err != nil {
+			errs = append(errs, fmt.Errorf("ringpop config `requiredMembers` contains invalid host:port %q", member))
+		}
+	}
+	if err := validateBootstrapMode(rpConfig); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// validate returns the first failure reported by ValidateAll
+func (rpConfig *Ringpop) validate() error {
+	if errs := rpConfig.ValidateAll(); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+func validateRingpopName(param string, name string) error {
+	if !validRingpopName.MatchString(name) {
+		return fmt.Errorf("ringpop config `%v` param %q contains invalid characters", param, name)
+	}
+	return nil
+}
+
+func validateNameCasing(rpConfig *Ringpop) error {
+	switch strings.ToLower(rpConfig.NameCasing) {
+	case "", nameCasingPreserve, nameCasingLower:
+	case nameCasingStrict:
+		if rpConfig.Name != strings.ToLower(rpConfig.Name) {
+			return fmt.Errorf("ringpop config `name` param %q must be lowercase", rpConfig.Name)
+		}
+		if rpConfig.AppName != strings.ToLower(rpConfig.AppName) {
+			return fmt.Errorf("ringpop config `appName` param %q must be lowercase", rpConfig.AppName)
+		}
+	default:
+		return fmt.Errorf("ringpop config `nameCasing` must be one of %v, %v or %v", nameCasingPreserve, nameCasingLower, nameCasingStrict)
+	}
+	return nil
+}
+
+// normalizeNames lowercases the ring names when asked to,
+// so that deployments differing only by casing share a ring
+func (factory *RingpopFactory) normalizeNames() {
+	rpConfig := factory.config
+	if strings.ToLower(rpConfig.NameCasing) != nameCasingLower {
+		return
+	}
+	name, appName := strings.ToLower(rpConfig.Name), strings.ToLower(rpConfig.AppName)
+	if name == rpConfig.Name && appName == rpConfig.AppName {
+		return
+	}
+	factory.logger.WithFields(bark.Fields{
+		"name":    rpConfig.Name,
+		"appName": rpConfig.AppName,
+	}).Info("Ringpop config names normalized to lowercase")
+	rpConfig.Name, rpConfig.AppName = name, appName
 }
 
 // UnmarshalYAML is called by the yaml package to convert
@@ -82,22 +450,96 @@ func (m *BootstrapMode) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return err
 }
 
+// String returns the config value of the bootstrap mode
+func (m BootstrapMode) String() string {
+	switch m {
+	case BootstrapModeHosts:
+		return "hosts"
+	case BootstrapModeFile:
+		return "file"
+	case BootstrapModeCustom:
+		return "custom"
+	case BootstrapModeComposite:
+		return "composite"
+	case BootstrapModeDNS:
+		return "dns"
+	case BootstrapModeS3:
+		return "s3"
+	case BootstrapModeNomad:
+		return "nomad"
+	case BootstrapModePeer:
+		return "peer"
+	case BootstrapModeZK:
+		return "zk"
+	case BootstrapModeK8sPods:
+		return "k8spods"
+	case BootstrapModeMDNS:
+		return "mdns"
+	case BootstrapModeEtcd:
+		return "etcd"
+	case BootstrapModeDNSSRV:
+		return "dnssrv"
+	case BootstrapModeSystemdCreds:
+		return "systemdcreds"
+	case BootstrapModeBlend:
+		return "blend"
+	case BootstrapModeMmap:
+		return "mmap"
+	case BootstrapModeFrontend:
+		return "frontend"
+	case BootstrapModeVault:
+		return "vault"
+	}
+	return "none"
+}
+
 // parseBootstrapMode reads a string value and returns a bootstrap mode.
+// The value is case insensitive and surrounding whitespace is ignored
 func parseBootstrapMode(s string) (BootstrapMode, error) {
-	switch strings.ToLower(s) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
 	case "hosts":
 		return BootstrapModeHosts, nil
 	case "file":
 		return BootstrapModeFile, nil
 	case "custom":
 		return BootstrapModeCustom, nil
+	case "composite":
+		return BootstrapModeComposite, nil
+	case "dns":
+		return BootstrapModeDNS, nil
+	case "s3":
+		return BootstrapModeS3, nil
+	case "nomad":
+		return BootstrapModeNomad, nil
+	case "peer":
+		return BootstrapModePeer, nil
+	case "zk":
+		return BootstrapModeZK, nil
+	case "k8spods":
+		return BootstrapModeK8sPods, nil
+	case "mdns":
+		return BootstrapModeMDNS, nil
+	case "etcd":
+		return BootstrapModeEtcd, nil
+	case "dnssrv":
+		return BootstrapModeDNSSRV, nil
+	case "systemdcreds":
+		return BootstrapModeSystemdCreds, nil
+	case "blend":
+		return BootstrapModeBlend, nil
+	case "mmap":
+		return BootstrapModeMmap, nil
+	case "frontend":
+		return BootstrapModeFrontend, nil
+	case "vault":
+		return BootstrapModeVault, nil
 	}
 	return BootstrapModeNone, errors.New("invalid or no ringpop bootstrap mode")
 }
 
 func validateBootstrapMode(rpConfig *Ringpop) error {
 	switch rpConfig.BootstrapMode {
-	case BootstrapModeFile:
+	case BootstrapModeFile, BootstrapModeMmap:
 		if len(rpConfig.BootstrapFile) == 0 {
 			return fmt.Errorf("ringpop config missing bootstrap file param")
 		}
@@ -109,52 +551,514 @@ func validateBootstrapMode(rpConfig *Ringpop) error {
 		if rpConfig.DiscoveryProvider == nil {
 			return fmt.Errorf("ringpop bootstrapMode is set to custom but discoveryProvider is nil")
 		}
+	case BootstrapModeDNS:
+		if len(rpConfig.BootstrapHosts) == 0 {
+			return fmt.Errorf("ringpop config missing boostrap hosts param")
+		}
+	case BootstrapModeDNSSRV:
+		if err := validateSRVNames(rpConfig.BootstrapHosts); err != nil {
+			return err
+		}
+	case BootstrapModeBlend:
+		if err := validateBlendMode(rpConfig); err != nil {
+			return err
+		}
+	case BootstrapModeSystemdCreds:
+		if err := validateSystemdCredential(rpConfig.BootstrapSystemdCredential); err != nil {
+			return err
+		}
+	case BootstrapModeS3:
+		if len(rpConfig.BootstrapS3Bucket) == 0 || len(rpConfig.BootstrapS3Key) == 0 {
+			return fmt.Errorf("ringpop config missing bootstrap s3 bucket or key param")
+		}
+	case BootstrapModeNomad:
+		if len(rpConfig.BootstrapNomadJob) == 0 {
+			return fmt.Errorf("ringpop config missing bootstrap nomad job param")
+		}
+		if len(rpConfig.BootstrapNomadPort) == 0 {
+			return fmt.Errorf("ringpop config missing bootstrap nomad port param")
+		}
+	case BootstrapModePeer:
+		if _, _, err := net.SplitHostPort(rpConfig.BootstrapPeer); err != nil {
+			return fmt.Errorf("ringpop config bootstrap peer param must be a host:port")
+		}
+	case BootstrapModeFrontend:
+		if _, _, err := net.SplitHostPort(rpConfig.BootstrapFrontendAddress); err != nil {
+			return fmt.Errorf("ringpop config bootstrap frontend address param must be a host:port")
+		}
+		if len(rpConfig.BootstrapFrontendService) == 0 {
+			return fmt.Errorf("ringpop config missing bootstrap frontend service param")
+		}
+	case BootstrapModeVault:
+		if err := validateVaultConfig(rpConfig.BootstrapVaultAddress, rpConfig.BootstrapVaultPath, rpConfig.BootstrapVaultField); err != nil {
+			return err
+		}
+	case BootstrapModeZK:
+		if len(zkServers(rpConfig.BootstrapZKConnect)) == 0 {
+			return fmt.Errorf("ringpop config missing bootstrap zk connect param")
+		}
+		if !strings.HasPrefix(rpConfig.BootstrapZKPath, "/") {
+			return fmt.Errorf("ringpop config bootstrap zk path param must be an absolute znode path")
+		}
+	case BootstrapModeK8sPods:
+		if len(rpConfig.BootstrapK8sNamespace) == 0 || len(rpConfig.BootstrapK8sSelector) == 0 {
+			return fmt.Errorf("ringpop config missing bootstrap k8s namespace or selector param")
+		}
+		if rpConfig.BootstrapK8sPort <= 0 || rpConfig.BootstrapK8sPort > 65535 {
+			return fmt.Errorf("ringpop config bootstrap k8s port param must be between 1 and 65535")
+		}
+	case BootstrapModeMDNS:
+		if err := validateMDNSService(rpConfig.BootstrapMDNSService); err != nil {
+			return err
+		}
+	case BootstrapModeEtcd:
+		if err := validateEtcdEndpoints(rpConfig.BootstrapEtcdEndpoints); err != nil {
+			return err
+		}
+		if len(rpConfig.BootstrapEtcdPrefix) == 0 {
+			return fmt.Errorf("ringpop config missing bootstrap etcd prefix param")
+		}
+	case BootstrapModeComposite:
+		numSources := len(compositeSources(rpConfig))
+		if numSources == 0 {
+			return fmt.Errorf("ringpop bootstrapMode is set to composite but no bootstrap source is configured")
+		}
+		if rpConfig.CompositeMinSuccessfulSources < 0 || rpConfig.CompositeMinSuccessfulSources > numSources {
+			return fmt.Errorf("ringpop config `compositeMinSuccessfulSources` must be between 0 and %v", numSources)
+		}
 	default:
 		return fmt.Errorf("ringpop config with unknown boostrap mode")
 	}
 	return nil
 }
 
-func newRingpopFactory(rpConfig *Ringpop) (*RingpopFactory, error) {
+func newRingpopFactory(rpConfig *Ringpop, opts ...FactoryOption) (*RingpopFactory, error) {
+	factory := &RingpopFactory{
+		config:       rpConfig,
+		logger:       bark.NewLoggerFromLogrus(logrus.StandardLogger()),
+		metricsScope: tally.NoopScope,
+		clock:        realClock{},
+		tracer:       noopTracer{},
+		shutdownCh:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(factory)
+	}
+	if factory.provider != nil {
+		// an injected provider takes precedence, just like a custom one
+		rpConfig.DiscoveryProvider = factory.provider
+		if rpConfig.BootstrapMode == BootstrapModeNone {
+			rpConfig.BootstrapMode = BootstrapModeCustom
+		}
+	}
+
 	if err := rpConfig.validate(); err != nil {
 		return nil, err
 	}
+	factory.bootstrapMode = rpConfig.BootstrapMode
+	factory.normalizeNames()
+	factory.applyMetricTags()
+	if factory.bootstrapMode == BootstrapModeMDNS {
+		factory.logger.WithField("service", rpConfig.BootstrapMDNSService).Warn(
+			"Ringpop mdns bootstrap is meant for isolated local networks, it is unsuitable for production and doesn't cross subnets")
+	}
 	if rpConfig.MaxJoinDuration == 0 {
-		rpConfig.MaxJoinDuration = defaultMaxJoinDuration
+		rpConfig.MaxJoinDuration = getDefaultMaxJoinDuration()
+	}
+	if len(rpConfig.AppName) == 0 {
+		rpConfig.AppName = rpConfig.Name
+	}
+	if rpConfig.CompositeMinSuccessfulSources == 0 {
+		rpConfig.CompositeMinSuccessfulSources = defaultCompositeMinSuccessfulSources
+	}
+	if rpConfig.ReconcileMinOverlap == 0 {
+		rpConfig.ReconcileMinOverlap = defaultReconcileMinOverlap
+	}
+	if rpConfig.BootstrapRetryInterval == 0 {
+		rpConfig.BootstrapRetryInterval = defaultBootstrapRetryInterval
+	}
+	if rpConfig.SeedSnapshotInterval == 0 {
+		rpConfig.SeedSnapshotInterval = defaultSeedSnapshotInterval
+	}
+	if rpConfig.SeedSnapshotMinHosts == 0 {
+		rpConfig.SeedSnapshotMinHosts = 1
+	}
+	if rpConfig.LeaveGracePeriod == 0 {
+		rpConfig.LeaveGracePeriod = defaultLeaveGracePeriod
+	}
+	if rpConfig.MembershipPollInterval == 0 {
+		rpConfig.MembershipPollInterval = defaultMembershipPollInterval
+	}
+	if rpConfig.MaxMembersCheckInterval == 0 {
+		rpConfig.MaxMembersCheckInterval = defaultMaxMembersCheckInterval
+	}
+	if rpConfig.BootstrapWarmupMax == 0 {
+		rpConfig.BootstrapWarmupMax = rpConfig.MaxJoinDuration
+	}
+	if rpConfig.DiscoveryQPS > 0 {
+		if rpConfig.DiscoveryBurst == 0 {
+			rpConfig.DiscoveryBurst = defaultDiscoveryBurst
+		}
+		factory.discoveryLimiter = rate.NewLimiter(rate.Limit(rpConfig.DiscoveryQPS), rpConfig.DiscoveryBurst)
+	}
+	if rpConfig.DiscoveryBreakerThreshold > 0 {
+		if rpConfig.DiscoveryBreakerCooldown == 0 {
+			rpConfig.DiscoveryBreakerCooldown = defaultDiscoveryBreakerCooldown
+		}
+		factory.discoveryBreaker = &discoveryBreaker{
+			threshold: rpConfig.DiscoveryBreakerThreshold,
+			cooldown:  rpConfig.DiscoveryBreakerCooldown,
+			clock:     factory.clock,
+			logger:    factory.logger,
+			scope:     factory.metricsScope,
+		}
 	}
-	return &RingpopFactory{config: rpConfig}, nil
+	return factory, nil
 }
 
 // CreateRingpop is the implementation for RingpopFactory.CreateRingpop
 func (factory *RingpopFactory) CreateRingpop(dispatcher *yarpc.Dispatcher) (*ringpop.Ringpop, error) {
+	return factory.CreateRingpopContext(context.Background(), dispatcher)
+}
+
+// CreateRingpopContext is like CreateRingpop, except that discovery
+// of the bootstrap hosts is abandoned when the context is done
+func (factory *RingpopFactory) CreateRingpopContext(ctx context.Context, dispatcher *yarpc.Dispatcher) (*ringpop.Ringpop, error) {
 	var ch *tcg.Channel
 	var err error
 	if ch, err = factory.getChannel(dispatcher); err != nil {
 		return nil, err
 	}
+	return factory.createRingpop(ctx, ch, 0)
+}
+
+// CreateRingpopWithJoinDuration is like CreateRingpop, except that the
+// ring is joined within the given max join duration instead of the one
+// of the config, which is left unchanged. Zero uses the config value
+func (factory *RingpopFactory) CreateRingpopWithJoinDuration(dispatcher *yarpc.Dispatcher, maxJoinDuration time.Duration) (*ringpop.Ringpop, error) {
+	if maxJoinDuration < 0 {
+		return nil, fmt.Errorf("ringpop max join duration must not be negative, got %v", maxJoinDuration)
+	}
+	ch, err := factory.getChannel(dispatcher)
+	if err != nil {
+		return nil, err
+	}
+	return factory.createRingpop(context.Background(), ch, maxJoinDuration)
+}
+
+// createRingpop resolves the bootstrap hosts and joins the ring over the
+// given channel, within the max join duration of the config unless
+// another one is given
+func (factory *RingpopFactory) createRingpop(ctx context.Context, ch *tcg.Channel, maxJoinDuration time.Duration) (rp *ringpop.Ringpop, err error) {
+	ctx, span := factory.tracer.StartSpan(ctx, spanBootstrap)
+	span.SetAttribute("ringpop.mode", factory.bootstrapMode.String())
+	defer func() {
+		span.End(err)
+	}()
+
+	if maxJoinDuration == 0 {
+		maxJoinDuration = factory.config.MaxJoinDuration
+	}
+	if err := factory.registerName(); err != nil {
+		return nil, err
+	}
+	factory.startBootstrapAttempt(ctx)
+	defer factory.endBootstrapAttempt()
+	if err := factory.settleDiscovery(ctx); err != nil {
+		factory.releaseName()
+		return nil, err
+	}
+
+	start := factory.clock.Now()
+	attempt := 0
+	selfOnly := factory.newSelfOnlyAbort()
+	err = factory.retryBootstrap(ctx, func() error {
+		attempt++
+		if attempt > 1 && factory.config.DNSForceFreshOnRetry {
+			factory.setFreshDNS(true)
+		}
+		prepared, err := factory.tracedResolveAndPrepare(ctx, ch, maxJoinDuration, attempt)
+		if err != nil {
+			return err
+		}
+		if err := selfOnly.check(prepared.Hosts(), prepared.selfAddress()); err != nil {
+			return err
+		}
+		rp, err = factory.tracedJoin(ctx, prepared, attempt)
+		return err
+	})
+	factory.setFreshDNS(false)
+	span.SetAttribute("ringpop.attempts", attempt)
+	factory.recordBootstrapLatency(factory.clock.Now().Sub(start), err)
+	if err != nil {
+		factory.releaseName()
+		return nil, err
+	}
+	factory.warmup(ctx, func() (int, error) {
+		return rp.CountReachableMembers()
+	})
+	return rp, nil
+}
+
+// PreparedBootstrap holds a resolved seed list that is ready to be
+// joined, it lets callers run discovery and the ring join separately
+type PreparedBootstrap struct {
+	factory         *RingpopFactory
+	channel         *tcg.Channel
+	address         string
+	hosts           []string
+	maxJoinDuration time.Duration
+}
+
+// ringpopOptions returns the options ringpop is created with
+func (factory *RingpopFactory) ringpopOptions(ch *tcg.Channel, address string, numSeeds int) []ringpop.Option {
+	opts := []ringpop.Option{ringpop.Channel(ch)}
+	if len(address) > 0 {
+		opts = append(opts, ringpop.Address(address))
+	}
+	if factory.config.DisableFaultyReaping {
+		opts = append(opts, ringpop.FaultyPeriod(unreapedFaultyPeriod))
+	}
+	if len(factory.config.NamePrefix) > 0 {
+		// members of rings with another prefix are rejected on ping
+		opts = append(opts, ringpop.RequiresAppInPing(true))
+	}
+	if timeout := factory.suspicionTimeout(numSeeds); timeout > 0 {
+		opts = append(opts, ringpop.SuspectPeriod(timeout))
+	}
+	if period := factory.config.TombstonePeriod; period > 0 {
+		opts = append(opts, ringpop.TombstonePeriod(period))
+	}
+	if count := factory.config.LabelLimitCount; count > 0 {
+		opts = append(opts, ringpop.LabelLimitCount(count))
+	}
+	if size := factory.config.LabelLimitValueSize; size > 0 {
+		opts = append(opts, ringpop.LabelLimitValueSize(size))
+	}
+	if points := factory.config.HashRingReplicaPoints; points > 0 {
+		opts = append(opts, ringpop.HashRingConfig(&hashring.Configuration{ReplicaPoints: points}))
+	}
+	return append(opts, factory.ringpopOptionsExtra...)
+}
+
+// ringAppName returns the app name the ring is created with,
+// which is the app name within the name prefix namespace
+func (factory *RingpopFactory) ringAppName() string {
+	return factory.config.NamePrefix + factory.config.AppName
+}
+
+// suspicionTimeout returns the swim suspect period for a ring of the given
+// size, or zero to keep the ringpop default. Ringpop can't change it once
+// created, so it is computed from the seed count when the ring is joined
+func (factory *RingpopFactory) suspicionTimeout(numMembers int) time.Duration {
+	cfg := factory.config
+	if cfg.SuspicionTimeoutBase == 0 && cfg.SuspicionTimeoutPerMember == 0 {
+		return 0
+	}
+	timeout := cfg.SuspicionTimeoutBase + time.Duration(numMembers)*cfg.SuspicionTimeoutPerMember
+	if cfg.SuspicionTimeoutMax > 0 && timeout > cfg.SuspicionTimeoutMax {
+		timeout = cfg.SuspicionTimeoutMax
+	}
+	return timeout
+}
+
+// ResolveAndPrepare resolves the bootstrap hosts for the given
+// channel without joining the ring, so that discovery errors
+// surface before the join is attempted
+func (factory *RingpopFactory) ResolveAndPrepare(ch *tcg.Channel) (*PreparedBootstrap, error) {
+	return factory.ResolveAndPrepareContext(context.Background(), ch)
+}
+
+// ResolveAndPrepareContext is like ResolveAndPrepare, except that
+// discovery is abandoned when the context is done
+func (factory *RingpopFactory) ResolveAndPrepareContext(ctx context.Context, ch *tcg.Channel) (*PreparedBootstrap, error) {
+	return factory.resolveAndPrepare(ctx, ch, factory.config.MaxJoinDuration)
+}
 
-	rp, err := ringpop.New(factory.config.Name, ringpop.Channel(ch))
+func (factory *RingpopFactory) resolveAndPrepare(ctx context.Context, ch *tcg.Channel, maxJoinDuration time.Duration) (*PreparedBootstrap, error) {
+	if factory.bootstrapMode == BootstrapModeMDNS {
+		// this node is advertised before browsing, so that it is found along with its peers
+		if err := factory.advertiseMDNS(ch); err != nil {
+			return nil, err
+		}
+	}
+	discoveryProvider, err := factory.discoveryProvider()
+	if err != nil {
+		return nil, err
+	}
+	release, err := factory.acquireDiscoverySlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// an abandoned discovery keeps its slot until the provider returns
+	hosts, err := hostsWithRelease(ctx, discoveryProvider, release)
 	if err != nil {
 		return nil, err
 	}
+	if hosts, err = factory.deriveSeedPorts(ch, hosts); err != nil {
+		return nil, err
+	}
+	factory.checkMaxJoinDuration(len(hosts), maxJoinDuration)
+	address, err := factory.advertisedAddress(ch)
+	if err != nil {
+		return nil, err
+	}
+	return &PreparedBootstrap{
+		factory:         factory,
+		channel:         ch,
+		address:         address,
+		hosts:           hosts,
+		maxJoinDuration: maxJoinDuration,
+	}, nil
+}
+
+// advertisedAddress returns the address ringpop advertises for the
+// channel, or an empty string when the channel address is used as is
+func (factory *RingpopFactory) advertisedAddress(ch *tcg.Channel) (string, error) {
+	address, err := advertiseHostPort(ch.PeerInfo().HostPort, factory.config.AdvertiseAddress, factory.config.AdvertisePort, net.InterfaceAddrs)
+	if err != nil {
+		return "", err
+	}
+	if factory.addressTransform != nil {
+		return factory.transformAdvertiseAddress(ch.PeerInfo().HostPort, address)
+	}
+	return address, nil
+}
+
+// Hosts returns the resolved bootstrap hosts
+func (prepared *PreparedBootstrap) Hosts() []string {
+	return prepared.hosts
+}
+
+// selfAddress returns the address this node joins the ring with
+func (prepared *PreparedBootstrap) selfAddress() string {
+	if len(prepared.address) > 0 {
+		return prepared.address
+	}
+	return prepared.channel.PeerInfo().HostPort
+}
 
-	discoveryProvider, err := newDiscoveryProvider(factory.config)
+// Join creates ringpop on the prepared channel and
+// bootstraps it using the resolved hosts
+func (prepared *PreparedBootstrap) Join() (*ringpop.Ringpop, error) {
+	factory := prepared.factory
+	rp, err := ringpop.New(factory.ringAppName(), factory.ringpopOptions(prepared.channel, prepared.address, len(prepared.hosts))...)
 	if err != nil {
 		return nil, err
 	}
+	rp.AddListener(&ringChangeListener{factory: factory})
+	metrics := newMembershipMetrics(factory, rp)
+	rp.AddListener(metrics)
+	rp.AddListener(&membershipCrossListener{factory: factory, countMembers: metrics.countMembers})
 
 	bootstrapOpts := &swim.BootstrapOptions{
-		MaxJoinDuration:  factory.config.MaxJoinDuration,
-		DiscoverProvider: discoveryProvider,
+		MaxJoinDuration:  prepared.maxJoinDuration,
+		JoinTimeout:      factory.config.RingpopRequestTimeout,
+		DiscoverProvider: prepared.discoverProvider(),
+	}
+
+	if factory.config.BootstrapProgressInterval > 0 {
+		progress := newBootstrapProgress(len(prepared.hosts), factory.clock.Now())
+		doneC := make(chan struct{})
+		rp.AddListener(progress)
+		go factory.logBootstrapProgress(progress, doneC)
+		defer func() {
+			close(doneC)
+			rp.RemoveListener(progress)
+		}()
 	}
 
 	_, err = rp.Bootstrap(bootstrapOpts)
 	if err != nil {
+		rp.Destroy()
+		return nil, err
+	}
+	if err := factory.setPartition(rp); err != nil {
+		rp.Destroy()
+		return nil, err
+	}
+	if err := factory.applyMaintenance(rp); err != nil {
+		rp.Destroy()
 		return nil, err
 	}
+	if err := factory.checkSelfOnly(rp); err != nil {
+		rp.Destroy()
+		return nil, err
+	}
+	if err := factory.checkMaxMembers(rp); err != nil {
+		rp.Destroy()
+		return nil, err
+	}
+
+	factory.mutex.Lock()
+	factory.channel = prepared.channel
+	factory.ringpop = rp
+	factory.mutex.Unlock()
+
+	factory.startReconciler(rp)
+	factory.startMaxMembersMonitor(rp)
+	factory.startSeedSnapshots(rp)
+	factory.startMembersSampler(metrics)
 	return rp, nil
 }
 
+// ResolvedBootstrapMode returns the bootstrap mode the factory
+// uses, after inferring it from the factory options
+func (factory *RingpopFactory) ResolvedBootstrapMode() BootstrapMode {
+	return factory.bootstrapMode
+}
+
+// EffectiveConfig returns a copy of the config the factory uses,
+// with the defaults and the inferred bootstrap mode filled in
+func (factory *RingpopFactory) EffectiveConfig() Ringpop {
+	effective := factory.config.clone()
+	effective.BootstrapMode = factory.bootstrapMode
+	return effective
+}
+
+// Stop stops the background loops started by the factory
+func (factory *RingpopFactory) Stop() {
+	factory.stopOnce.Do(func() {
+		factory.mutex.Lock()
+		close(factory.shutdownCh)
+		factory.mutex.Unlock()
+	})
+	factory.shutdownWG.Wait()
+}
+
+// checkMaxJoinDuration logs a warning when the max join duration is likely
+// too short to contact the given number of seeds. This is only a heuristic
+// and doesn't change how the join is performed.
+func (factory *RingpopFactory) checkMaxJoinDuration(numSeeds int, maxJoinDuration time.Duration) {
+	joinTimeout := factory.joinTimeout()
+	expected := expectedJoinDuration(numSeeds, joinTimeout)
+	if maxJoinDuration >= expected {
+		return
+	}
+	factory.bootstrapLogger().WithFields(bark.Fields{
+		"seeds":            numSeeds,
+		"seedJoinTimeout":  joinTimeout,
+		"joinParallelism":  swimJoinParallelism,
+		"expectedDuration": expected,
+		"maxJoinDuration":  maxJoinDuration,
+	}).Warn("Ringpop maxJoinDuration may be too short to contact all seeds, consider increasing it")
+}
+
+// expectedJoinDuration is a rough estimate of the time
+// it takes to contact the given number of seeds
+func expectedJoinDuration(numSeeds int, joinTimeout time.Duration) time.Duration {
+	rounds := (numSeeds + swimJoinParallelism - 1) / swimJoinParallelism
+	return time.Duration(rounds) * joinTimeout
+}
+
+// joinTimeout returns the timeout of the join requests sent to the seeds
+func (factory *RingpopFactory) joinTimeout() time.Duration {
+	if factory.config.RingpopRequestTimeout > 0 {
+		return factory.config.RingpopRequestTimeout
+	}
+	return swimJoinTimeout
+}
+
 func (factory *RingpopFactory) getChannel(dispatcher *yarpc.Dispatcher) (*tcg.Channel, error) {
 	t := dispatcher.Inbounds()[0].Transports()[0].(*tchannel.ChannelTransport)
 	ty := reflect.ValueOf(t.Channel())
@@ -166,7 +1070,14 @@ func (factory *RingpopFactory) getChannel(dispatcher *yarpc.Dispatcher) (*tcg.Ch
 	return ch, nil
 }
 
-func newDiscoveryProvider(cfg *Ringpop) (discovery.DiscoverProvider, error) {
+func newSourceDiscoveryProvider(cfg *Ringpop) (discovery.DiscoverProvider, error) {
+
+	if cfg.BootstrapMode == BootstrapModeComposite {
+		return newCompositeProvider(cfg.CompositeMinSuccessfulSources, compositeSources(cfg)), nil
+	}
+	if cfg.BootstrapMode == BootstrapModeBlend {
+		return newBlendProvider(cfg)
+	}
 
 	if cfg.DiscoveryProvider != nil {
 		// custom discovery provider takes first precedence
@@ -175,9 +1086,35 @@ func newDiscoveryProvider(cfg *Ringpop) (discovery.DiscoverProvider, error) {
 
 	switch cfg.BootstrapMode {
 	case BootstrapModeHosts:
-		return statichosts.New(cfg.BootstrapHosts...), nil
+		return newStaticProvider(cfg.BootstrapHosts), nil
 	case BootstrapModeFile:
-		return jsonfile.New(cfg.BootstrapFile), nil
+		return newFileProvider(cfg.BootstrapFile, cfg.BootstrapFileFormat), nil
+	case BootstrapModeMmap:
+		return newMmapProvider(cfg.BootstrapFile, cfg.BootstrapFileFormat), nil
+	case BootstrapModeDNS:
+		return newDNSProvider(cfg.BootstrapHosts, cfg.Resolver), nil
+	case BootstrapModeDNSSRV:
+		return newSRVProvider(cfg.BootstrapHosts, cfg.HonorSRVPriority, cfg.Resolver), nil
+	case BootstrapModeSystemdCreds:
+		return newSystemdCredsProvider(cfg.BootstrapSystemdCredential, cfg.BootstrapFileFormat), nil
+	case BootstrapModePeer:
+		return newPeerProvider(cfg.AppName, cfg.BootstrapPeer, cfg.MaxJoinDuration), nil
+	case BootstrapModeFrontend:
+		return newFrontendProvider(cfg.AppName, cfg.BootstrapFrontendAddress, cfg.BootstrapFrontendService, cfg.MaxJoinDuration), nil
+	case BootstrapModeNomad:
+		return newNomadProvider(cfg.BootstrapNomadAddress, cfg.BootstrapNomadJob, cfg.BootstrapNomadPort, cfg.MaxJoinDuration), nil
+	case BootstrapModeVault:
+		return newVaultProvider(cfg.BootstrapVaultAddress, cfg.BootstrapVaultPath, cfg.BootstrapVaultField, cfg.BootstrapFileFormat, cfg.MaxJoinDuration), nil
+	case BootstrapModeS3:
+		return newS3Provider(cfg.BootstrapS3Bucket, cfg.BootstrapS3Key, cfg.BootstrapS3Region, cfg.BootstrapFileFormat, cfg.MaxJoinDuration), nil
+	case BootstrapModeMDNS:
+		return newMDNSProvider(cfg.BootstrapMDNSService, cfg.MaxJoinDuration), nil
+	case BootstrapModeK8sPods:
+		return newK8sPodsProvider(cfg.BootstrapK8sNamespace, cfg.BootstrapK8sSelector, cfg.BootstrapK8sPort, cfg.MaxJoinDuration), nil
+	case BootstrapModeZK:
+		return newZKProvider(zkServers(cfg.BootstrapZKConnect), cfg.BootstrapZKPath, cfg.BootstrapZKReadData, cfg.BootstrapFileFormat, cfg.MaxJoinDuration), nil
+	case BootstrapModeEtcd:
+		return newEtcdProvider(cfg.BootstrapEtcdEndpoints, cfg.BootstrapEtcdPrefix, cfg.BootstrapEtcdRequireLease, cfg.MaxJoinDuration), nil
 	}
 	return nil, fmt.Errorf("unknown bootstrap mode")
 }
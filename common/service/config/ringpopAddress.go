@@ -0,0 +1,162 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// advertiseHostPort returns the host:port that ringpop should use as
+// the identity of a channel listening on hostPort, or an empty string
+// when hostPort can be used as is. An advertise address, when set,
+// always takes precedence. Otherwise a wildcard listen address is
+// replaced by the first routable address of the local interfaces,
+// and the port is replaced by the advertise port when one is set
+func advertiseHostPort(hostPort string, advertise string, advertisePort int, interfaceAddrs func() ([]net.Addr, error)) (string, error) {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return "", fmt.Errorf("ringpop tchannel has invalid host:port %q: %v", hostPort, err)
+	}
+	if advertisePort > 0 {
+		port = strconv.Itoa(advertisePort)
+	}
+	if len(advertise) > 0 {
+		if _, _, err := net.SplitHostPort(advertise); err == nil {
+			return advertise, nil
+		}
+		return net.JoinHostPort(advertise, port), nil
+	}
+
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		addrs, err := interfaceAddrs()
+		if err != nil {
+			return "", fmt.Errorf("ringpop tchannel listens on wildcard address %v and interface addresses are unavailable: %v", hostPort, err)
+		}
+		ip = routableIP(addrs)
+		if ip == nil {
+			return "", fmt.Errorf("ringpop tchannel listens on wildcard address %v and no routable address was found, set `advertiseAddress`", hostPort)
+		}
+		host = ip.String()
+	}
+
+	if advertised := net.JoinHostPort(host, port); advertised != hostPort {
+		return advertised, nil
+	}
+	return "", nil
+}
+
+// transformHosts applies the address transform to the seeds
+func (factory *RingpopFactory) transformHosts(hosts []string) ([]string, error) {
+	transformed := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		addr, err := factory.addressTransform(host)
+		if err != nil {
+			return nil, fmt.Errorf("ringpop address transform failed for seed %v: %v", host, err)
+		}
+		transformed = append(transformed, addr)
+	}
+	return transformed, nil
+}
+
+// transformAdvertiseAddress applies the address transform to the advertised
+// address, or to the channel address when it is advertised as is
+func (factory *RingpopFactory) transformAdvertiseAddress(hostPort string, advertised string) (string, error) {
+	if len(advertised) == 0 {
+		advertised = hostPort
+	}
+	addr, err := factory.addressTransform(advertised)
+	if err != nil {
+		return "", fmt.Errorf("ringpop address transform failed for self address %v: %v", advertised, err)
+	}
+	if addr == hostPort {
+		return "", nil
+	}
+	return addr, nil
+}
+
+// routableIP returns the first global unicast ip, preferring ipv4
+func routableIP(addrs []net.Addr) net.IP {
+	var found net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || !ipNet.IP.IsGlobalUnicast() {
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			return ipNet.IP
+		}
+		if found == nil {
+			found = ipNet.IP
+		}
+	}
+	return found
+}
+
+// advertisePrecedence explains how the advertised address is chosen, see advertiseHostPort
+const advertisePrecedence = "the advertised address is `advertiseAddress` when it has a port, " +
+	"otherwise its host is `advertiseAddress`, or the first routable interface address when listening on a wildcard address, " +
+	"and its port is `advertisePort`, or the listen port which `portAutoIncrement` may change"
+
+// validateAdvertiseAddress checks that the advertise address is
+// either an ip or an ip:port, and that the advertise port is valid
+func validateAdvertiseAddress(advertise string, advertisePort int) error {
+	if advertisePort < 0 || advertisePort > 65535 {
+		return fmt.Errorf("ringpop config `advertisePort` %v must be between 1 and 65535", advertisePort)
+	}
+	if len(advertise) == 0 {
+		return nil
+	}
+	host := advertise
+	if h, _, err := net.SplitHostPort(advertise); err == nil {
+		host = h
+	}
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		return fmt.Errorf("ringpop config `advertiseAddress` %q must be a routable ip or ip:port", advertise)
+	}
+	return nil
+}
+
+// validateAdvertiseConflicts checks that the advertise settings don't
+// contradict each other, the error lists the conflicting settings along
+// with the precedence rules
+func validateAdvertiseConflicts(rpConfig *Ringpop) error {
+	var conflicts []string
+	_, _, err := net.SplitHostPort(rpConfig.AdvertiseAddress)
+	addressHasPort := err == nil
+	if addressHasPort && rpConfig.AdvertisePort > 0 {
+		conflicts = append(conflicts, "`advertiseAddress` has a port and `advertisePort` is set, only one of them can set the advertised port")
+	}
+	if rpConfig.PortAutoIncrement > 0 {
+		if rpConfig.AdvertisePort > 0 {
+			conflicts = append(conflicts, "`advertisePort` and `portAutoIncrement` are both set, the advertised port wouldn't follow the auto incremented listen port")
+		}
+		if addressHasPort {
+			conflicts = append(conflicts, "`advertiseAddress` has a port and `portAutoIncrement` is set, the advertised port wouldn't follow the auto incremented listen port")
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	return fmt.Errorf("ringpop config has conflicting advertise settings: %v; %v", strings.Join(conflicts, "; "), advertisePrecedence)
+}
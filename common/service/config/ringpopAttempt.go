@@ -0,0 +1,81 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"context"
+
+	"github.com/pborman/uuid"
+	"github.com/uber-common/bark"
+)
+
+// bootstrapAttemptIDField is the log field carrying the bootstrap attempt id
+const bootstrapAttemptIDField = "bootstrapAttemptID"
+
+type bootstrapAttemptIDKey struct{}
+
+// WithBootstrapAttemptID returns a context that makes CreateRingpopContext
+// use the given id for its bootstrap attempt, like an id from a trace,
+// instead of generating one
+func WithBootstrapAttemptID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, bootstrapAttemptIDKey{}, id)
+}
+
+// BootstrapAttemptID returns the id of the last bootstrap attempt of
+// the factory, which is attached to the logs of that attempt
+func (factory *RingpopFactory) BootstrapAttemptID() string {
+	factory.mutex.Lock()
+	defer factory.mutex.Unlock()
+	return factory.lastAttemptID
+}
+
+// startBootstrapAttempt sets the id of the bootstrap attempt
+// starting, either taken from the context or generated
+func (factory *RingpopFactory) startBootstrapAttempt(ctx context.Context) string {
+	id, _ := ctx.Value(bootstrapAttemptIDKey{}).(string)
+	if len(id) == 0 {
+		id = uuid.New()
+	}
+	factory.mutex.Lock()
+	defer factory.mutex.Unlock()
+	factory.attemptID, factory.lastAttemptID = id, id
+	return id
+}
+
+// endBootstrapAttempt clears the id of the bootstrap attempt in progress
+func (factory *RingpopFactory) endBootstrapAttempt() {
+	factory.mutex.Lock()
+	defer factory.mutex.Unlock()
+	factory.attemptID = ""
+}
+
+// bootstrapLogger returns the logger for the bootstrap phases, which
+// carries the id of the bootstrap attempt in progress, if any
+func (factory *RingpopFactory) bootstrapLogger() bark.Logger {
+	factory.mutex.Lock()
+	id := factory.attemptID
+	factory.mutex.Unlock()
+
+	if len(id) == 0 {
+		return factory.logger
+	}
+	return factory.logger.WithField(bootstrapAttemptIDField, id)
+}
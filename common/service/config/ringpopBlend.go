@@ -0,0 +1,114 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/uber-common/bark"
+	"github.com/uber/cadence/common/logging"
+)
+
+// blendProvider anchors the seeds on the bootstrap file and appends the
+// hosts of a dynamic source the file doesn't list yet, up to maxDynamic
+// of them. A failed dynamic source is logged and leaves the file seeds alone
+type blendProvider struct {
+	file       namedProvider
+	dynamic    namedProvider
+	maxDynamic int
+	logger     bark.Logger
+}
+
+func newBlendProvider(cfg *Ringpop) (*blendProvider, error) {
+	dynamic, err := newSourceDiscoveryProvider(blendDynamicConfig(cfg))
+	if err != nil {
+		return nil, err
+	}
+	return &blendProvider{
+		file:       namedProvider{name: BootstrapModeFile.String(), provider: newFileProvider(cfg.BootstrapFile, cfg.BootstrapFileFormat)},
+		dynamic:    namedProvider{name: cfg.BlendDynamicMode.String(), provider: dynamic},
+		maxDynamic: cfg.MaxDynamicSeeds,
+		logger:     bark.NewLoggerFromLogrus(logrus.StandardLogger()),
+	}, nil
+}
+
+// blendDynamicConfig returns the config of the dynamic source of the
+// blend bootstrap mode, the custom provider is only used when it is
+// the dynamic source
+func blendDynamicConfig(cfg *Ringpop) *Ringpop {
+	dynamic := *cfg
+	dynamic.BootstrapMode = cfg.BlendDynamicMode
+	if dynamic.BootstrapMode != BootstrapModeCustom {
+		dynamic.DiscoveryProvider = nil
+	}
+	return &dynamic
+}
+
+// Hosts implements discovery.DiscoverProvider
+func (p *blendProvider) Hosts() ([]string, error) {
+	hosts, err := p.file.provider.Hosts()
+	if err != nil {
+		return nil, err
+	}
+	hosts = dedupHosts(hosts)
+	dynamic, err := p.dynamic.provider.Hosts()
+	if err != nil {
+		p.logger.WithFields(bark.Fields{
+			logging.TagErr: err,
+			"source":       p.dynamic.name,
+			"hosts":        len(hosts),
+		}).Warn("Ringpop blend discovery failed to read its dynamic source, bootstrapping from the file seeds alone")
+		return hosts, nil
+	}
+
+	known := make(map[string]struct{}, len(hosts))
+	for _, host := range hosts {
+		known[host] = struct{}{}
+	}
+	added := 0
+	for _, host := range dynamic {
+		if p.maxDynamic > 0 && added >= p.maxDynamic {
+			break
+		}
+		if _, ok := known[host]; ok {
+			continue
+		}
+		known[host] = struct{}{}
+		hosts = append(hosts, host)
+		added++
+	}
+	return hosts, nil
+}
+
+func validateBlendMode(rpConfig *Ringpop) error {
+	if len(rpConfig.BootstrapFile) == 0 {
+		return fmt.Errorf("ringpop config missing bootstrap file param")
+	}
+	if rpConfig.MaxDynamicSeeds < 0 {
+		return fmt.Errorf("ringpop config `maxDynamicSeeds` must not be negative")
+	}
+	switch rpConfig.BlendDynamicMode {
+	case BootstrapModeNone, BootstrapModeFile, BootstrapModeHosts, BootstrapModeComposite, BootstrapModeBlend, BootstrapModeMDNS:
+		return fmt.Errorf("ringpop config `blendDynamicMode` must be a dynamic bootstrap mode, got %v", rpConfig.BlendDynamicMode)
+	}
+	return validateBootstrapMode(blendDynamicConfig(rpConfig))
+}
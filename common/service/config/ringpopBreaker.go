@@ -0,0 +1,130 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"sync"
+	"time"
+
+	"github.com/uber-common/bark"
+	"github.com/uber-go/tally"
+	"github.com/uber/cadence/common/logging"
+	"github.com/uber/ringpop-go/discovery"
+)
+
+const (
+	// discoveryBreakerOpenedMetric and discoveryBreakerClosedMetric
+	// count the transitions of the discovery circuit breaker
+	discoveryBreakerOpenedMetric = "ringpop.discovery.breaker.opened"
+	discoveryBreakerClosedMetric = "ringpop.discovery.breaker.closed"
+)
+
+type (
+	// discoveryBreaker is a circuit breaker around discovery. It opens after
+	// threshold consecutive failures, provided discovery succeeded before,
+	// and then serves the last good seeds for the cooldown. Discovery is
+	// probed again once the cooldown is over, a success closes the breaker
+	// and a failure opens it for another cooldown
+	discoveryBreaker struct {
+		threshold int
+		cooldown  time.Duration
+		clock     Clock
+		logger    bark.Logger
+		scope     tally.Scope
+
+		mutex    sync.Mutex
+		failures int
+		open     bool
+		openedAt time.Time
+		lastGood []string
+	}
+
+	// breakerProvider is a discovery provider guarded by a circuit breaker
+	breakerProvider struct {
+		provider discovery.DiscoverProvider
+		breaker  *discoveryBreaker
+	}
+)
+
+// Hosts implements discovery.DiscoverProvider
+func (p *breakerProvider) Hosts() ([]string, error) {
+	if hosts, ok := p.breaker.cached(); ok {
+		return hosts, nil
+	}
+	hosts, err := p.provider.Hosts()
+	if open := p.breaker.record(hosts, err); open {
+		return p.breaker.lastGoodHosts(), nil
+	}
+	return hosts, err
+}
+
+// cached returns the last good seeds while the breaker is open and cooling down
+func (b *discoveryBreaker) cached() ([]string, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if !b.open || b.clock.Now().Sub(b.openedAt) >= b.cooldown {
+		return nil, false
+	}
+	return append([]string(nil), b.lastGood...), true
+}
+
+func (b *discoveryBreaker) lastGoodHosts() []string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]string(nil), b.lastGood...)
+}
+
+// record updates the breaker with the outcome of a discovery
+// call and returns true when the breaker is open afterwards
+func (b *discoveryBreaker) record(hosts []string, err error) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if err == nil {
+		b.failures = 0
+		b.lastGood = append([]string(nil), hosts...)
+		if b.open {
+			b.open = false
+			b.scope.Counter(discoveryBreakerClosedMetric).Inc(1)
+			b.logger.Info("Ringpop discovery recovered, closing the discovery circuit breaker")
+		}
+		return false
+	}
+
+	b.failures++
+	if b.open {
+		// the probe failed, cool down again
+		b.openedAt = b.clock.Now()
+		return true
+	}
+	if b.failures < b.threshold || len(b.lastGood) == 0 {
+		return false
+	}
+	b.open = true
+	b.openedAt = b.clock.Now()
+	b.scope.Counter(discoveryBreakerOpenedMetric).Inc(1)
+	b.logger.WithFields(bark.Fields{
+		logging.TagErr: err,
+		"failures":     b.failures,
+		"cooldown":     b.cooldown,
+		"seeds":        len(b.lastGood),
+	}).Warn("Ringpop discovery keeps failing, opening the discovery circuit breaker and serving the last good seeds")
+	return true
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/uber-common/bark"
+	"github.com/uber/cadence/common/logging"
+	"github.com/uber/ringpop-go"
+	tcg "github.com/uber/tchannel-go"
+)
+
+// CreateRingpopOwnChannel is like CreateRingpop, except that ringpop gets a
+// dedicated tchannel listening on listenAddr instead of sharing the channel
+// of the service dispatcher, so gossip doesn't compete with service traffic.
+// The factory owns that channel and closes it in Destroy. With a port
+// auto increment, the next free port after the one of listenAddr is used
+// when it is taken
+func (factory *RingpopFactory) CreateRingpopOwnChannel(listenAddr string) (*ringpop.Ringpop, error) {
+	increment := factory.config.PortAutoIncrement
+	if err := validateListenAddress(listenAddr, increment); err != nil {
+		return nil, err
+	}
+	ch, err := tcg.NewChannel(factory.config.Name, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ringpop: unable to create tchannel: %v", err)
+	}
+	listener, err := listenWithAutoIncrement(listenAddr, increment)
+	if err != nil {
+		ch.Close()
+		return nil, err
+	}
+	if increment > 0 {
+		factory.logger.WithFields(bark.Fields{
+			"listenAddress": listenAddr,
+			"address":       listener.Addr().String(),
+		}).Info("Ringpop auto incremented listen port")
+	}
+	if err := ch.Serve(listener); err != nil {
+		listener.Close()
+		ch.Close()
+		return nil, fmt.Errorf("ringpop: unable to serve on %v: %v", listener.Addr(), err)
+	}
+
+	rp, err := factory.createRingpop(context.Background(), ch, 0)
+	if err != nil {
+		ch.Close()
+		return nil, err
+	}
+	factory.mutex.Lock()
+	factory.ownChannel = ch
+	factory.mutex.Unlock()
+	return rp, nil
+}
+
+// Channel returns the tchannel ringpop runs on, whether it was given by
+// the dispatcher or created by the factory, or nil when the ring wasn't
+// joined. It is meant for introspection, like inspecting the peers or
+// the stats of the channel. Changing it may interfere with ringpop
+func (factory *RingpopFactory) Channel() *tcg.Channel {
+	ch, rp := factory.instance()
+	if rp == nil {
+		return nil
+	}
+	return ch
+}
+
+// Destroy stops the background loops of the factory, leaves the ring and
+// waits for the leave grace period so that the leave can be gossiped to
+// the peers, then destroys the ringpop instance it created and closes the
+// tchannel it owns, if any
+func (factory *RingpopFactory) Destroy() {
+	factory.Stop()
+
+	factory.mutex.Lock()
+	rp, ch, left := factory.ringpop, factory.ownChannel, factory.left
+	factory.ringpop, factory.ownChannel = nil, nil
+	factory.mutex.Unlock()
+
+	if rp != nil {
+		if !left {
+			factory.leave(rp.SelfEvict)
+		}
+		rp.Destroy()
+	}
+	if ch != nil {
+		ch.Close()
+	}
+	factory.releaseName()
+}
+
+// Leave announces that this node leaves the ring, without destroying the
+// ringpop instance, so that its ownership can drain before Destroy. See
+// PrepareLeave and WaitUntilDrained
+func (factory *RingpopFactory) Leave() error {
+	factory.mutex.Lock()
+	rp, left := factory.ringpop, factory.left
+	if rp != nil {
+		factory.left = true
+	}
+	factory.mutex.Unlock()
+	if rp == nil {
+		return ErrRingpopNotCreated
+	}
+	if left {
+		return nil
+	}
+
+	// the self evict pings the peers, which mustn't hold the mutex
+	if err := rp.SelfEvict(); err != nil {
+		factory.mutex.Lock()
+		factory.left = false
+		factory.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+// leave announces that this node leaves the ring and gives
+// the leave the grace period to propagate
+func (factory *RingpopFactory) leave(selfEvict func() error) {
+	grace := factory.config.LeaveGracePeriod
+	if grace <= 0 {
+		return
+	}
+	if err := selfEvict(); err != nil {
+		factory.logger.WithFields(bark.Fields{logging.TagErr: err}).Warn("Ringpop failed to leave the ring before destroy")
+		return
+	}
+	<-factory.clock.After(grace)
+}
+
+// listenWithAutoIncrement listens on addr or, when its port is taken,
+// on the first free port among the next increment ports
+func listenWithAutoIncrement(addr string, increment int) (net.Listener, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	base, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, err
+	}
+	for p := base; p <= base+increment; p++ {
+		var listener net.Listener
+		if listener, err = net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(p))); err == nil {
+			return listener, nil
+		}
+	}
+	if increment > 0 {
+		return nil, fmt.Errorf("ringpop: unable to listen on %v or the next %v ports: %v", addr, increment, err)
+	}
+	return nil, fmt.Errorf("ringpop: unable to listen on %v: %v", addr, err)
+}
+
+// validateListenAddress checks that addr is a host:port a dedicated
+// ringpop channel can listen on, with a base port leaving room for
+// the given port auto increment
+func validateListenAddress(addr string, increment int) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("ringpop listen address %q must be host:port: %v", addr, err)
+	}
+	if len(host) > 0 && net.ParseIP(host) == nil {
+		return fmt.Errorf("ringpop listen address %q must have an ip host", addr)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 0 || p > 65535 {
+		return fmt.Errorf("ringpop listen address %q has invalid port", addr)
+	}
+	if increment > 0 && (p == 0 || p+increment > 65535) {
+		return fmt.Errorf("ringpop listen address %q must have a base port between 1 and %v to auto increment", addr, 65535-increment)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// dnsNoSuchHost is the error reported by the resolver for NXDOMAIN
+const dnsNoSuchHost = "no such host"
+
+type (
+	// Resolver looks up dns names, *net.Resolver implements it. A fake
+	// resolver lets the dns bootstrap modes run without a dns server
+	Resolver interface {
+		LookupHost(ctx context.Context, host string) ([]string, error)
+		LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+	}
+
+	// DNSLookupError is returned by the dns bootstrap mode when a
+	// hostname can't be resolved. Temporary tells whether the lookup
+	// is worth retrying, a non existent name is a permanent failure
+	DNSLookupError struct {
+		Host      string
+		Temporary bool
+		Err       error
+	}
+
+	// dnsProvider is a discovery provider that resolves
+	// a list of hostname:port into ip:port
+	dnsProvider struct {
+		hosts    []string
+		resolver Resolver
+	}
+)
+
+// Error implements error
+func (e *DNSLookupError) Error() string {
+	return fmt.Sprintf("ringpop dns lookup of %v failed: %v", e.Host, e.Err)
+}
+
+func newDNSProvider(hosts []string, resolver Resolver) *dnsProvider {
+	return &dnsProvider{hosts: hosts, resolver: resolverOrDefault(resolver)}
+}
+
+// freshResolver is the resolver of the bootstrap attempts that must bypass
+// the dns caches. The pure go resolver sends its queries to the name servers
+// of resolv.conf, rather than through libc and a caching daemon like nscd
+var freshResolver Resolver = &net.Resolver{PreferGo: true}
+
+// resolverOrDefault returns the resolver, or the default one when it is nil
+func resolverOrDefault(resolver Resolver) Resolver {
+	if resolver == nil {
+		return net.DefaultResolver
+	}
+	return resolver
+}
+
+// Hosts implements discovery.DiscoverProvider
+func (p *dnsProvider) Hosts() ([]string, error) {
+	var result []string
+	for _, hostPort := range p.hosts {
+		host, port, err := net.SplitHostPort(hostPort)
+		if err != nil {
+			return nil, fmt.Errorf("ringpop dns bootstrap host %q is not a hostname:port", hostPort)
+		}
+		addrs, err := p.resolver.LookupHost(context.Background(), host)
+		if err != nil {
+			return nil, newDNSLookupError(host, err)
+		}
+		for _, addr := range addrs {
+			result = append(result, net.JoinHostPort(addr, port))
+		}
+	}
+	return result, nil
+}
+
+func newDNSLookupError(host string, err error) *DNSLookupError {
+	temporary := true
+	if dnsErr, ok := err.(*net.DNSError); ok {
+		temporary = dnsErr.Err != dnsNoSuchHost
+	}
+	return &DNSLookupError{
+		Host:      host,
+		Temporary: temporary,
+		Err:       err,
+	}
+}
+
+// setFreshDNS sets whether discovery must bypass the dns caches
+func (factory *RingpopFactory) setFreshDNS(fresh bool) {
+	factory.mutex.Lock()
+	defer factory.mutex.Unlock()
+	factory.freshDNS = fresh
+}
+
+// sourceConfig returns the config the bootstrap source is built from, which
+// resolves the dns names with the fresh resolver while discovery must bypass
+// the dns caches and no custom resolver is set
+func (factory *RingpopFactory) sourceConfig() *Ringpop {
+	factory.mutex.Lock()
+	fresh := factory.freshDNS
+	factory.mutex.Unlock()
+	if !fresh || factory.config.Resolver != nil {
+		return factory.config
+	}
+	cfg := *factory.config
+	cfg.Resolver = freshResolver
+	return &cfg
+}
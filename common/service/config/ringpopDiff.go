@@ -0,0 +1,91 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// DiffMembership compares two views of the ring membership, like the
+// Members of two nodes, and returns the members only found in a and
+// the ones only found in b, in their order. Addresses are compared
+// normalized, so an ipv4-mapped ipv6 address matches its ipv4 form and
+// a port with leading zeros matches the plain one
+func DiffMembership(a, b []string) (onlyA, onlyB []string) {
+	keysA := membershipKeys(a)
+	keysB := membershipKeys(b)
+	return membershipDiff(a, keysB), membershipDiff(b, keysA)
+}
+
+// CompareWith compares the membership seen by this node with the one
+// seen by a peer, and returns the members only this node sees and the
+// ones only the peer sees. Empty results mean that both views agree
+func (factory *RingpopFactory) CompareWith(peerMembership []string) (onlySelf, onlyPeer []string, err error) {
+	members, err := factory.Members()
+	if err != nil {
+		return nil, nil, err
+	}
+	onlySelf, onlyPeer = DiffMembership(members, peerMembership)
+	return onlySelf, onlyPeer, nil
+}
+
+// membershipDiff returns the members whose key is not in keys,
+// without duplicates
+func membershipDiff(members []string, keys map[string]struct{}) []string {
+	var diff []string
+	seen := make(map[string]struct{}, len(members))
+	for _, member := range members {
+		key := membershipKey(member)
+		if _, ok := keys[key]; ok {
+			continue
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		diff = append(diff, member)
+	}
+	return diff
+}
+
+func membershipKeys(members []string) map[string]struct{} {
+	keys := make(map[string]struct{}, len(members))
+	for _, member := range members {
+		keys[membershipKey(member)] = struct{}{}
+	}
+	return keys
+}
+
+// membershipKey normalizes a member address for comparison: ips are
+// canonicalized, hostnames lowercased and ports stripped of leading zeros
+func membershipKey(member string) string {
+	member = strings.TrimSpace(member)
+	host, port, err := net.SplitHostPort(member)
+	if err != nil {
+		return strings.ToLower(normalizeHost(member, addressNormalizationIPv4))
+	}
+	if p, err := strconv.Atoi(port); err == nil {
+		port = strconv.Itoa(p)
+	}
+	return strings.ToLower(normalizeHost(net.JoinHostPort(host, port), addressNormalizationIPv4))
+}
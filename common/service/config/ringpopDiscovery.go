@@ -0,0 +1,483 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/uber/ringpop-go/discovery"
+	"github.com/uber/ringpop-go/discovery/statichosts"
+)
+
+const (
+	addressNormalizationNone = "none"
+	addressNormalizationIPv4 = "ipv4"
+	addressNormalizationIPv6 = "ipv6"
+)
+
+// newStaticProvider builds the provider of a static list of seeds, for the
+// hosts bootstrap mode and for the seeds ringpop is joined with. It is a
+// seam for tests to substitute a fake, or to wrap the static provider with
+// some instrumentation, and must not be changed while a factory is in use
+var newStaticProvider = func(hosts []string) discovery.DiscoverProvider {
+	return statichosts.New(hosts...)
+}
+
+type (
+	// seedListStep is a single post-processing step
+	// applied to the hosts returned by a provider
+	seedListStep func(hosts []string) ([]string, error)
+
+	// seedListProvider wraps a discovery provider and runs
+	// the hosts it returns through a list of steps
+	seedListProvider struct {
+		provider discovery.DiscoverProvider
+		steps    []seedListStep
+	}
+
+	// namedProvider is a discovery provider along with
+	// the name of the source it reads from
+	namedProvider struct {
+		name     string
+		provider discovery.DiscoverProvider
+	}
+
+	// compositeProvider queries all of its sources in parallel and
+	// merges their hosts, as long as at least minSuccessful of them
+	// returned a non-empty result
+	compositeProvider struct {
+		minSuccessful int
+		sources       []namedProvider
+	}
+
+	// CompositeDiscoveryError is returned by the composite bootstrap mode
+	// when fewer than MinSuccessful of its sources succeeded. Causes holds
+	// the error of every failed source, keyed by the name in Failed at the
+	// same index
+	CompositeDiscoveryError struct {
+		Succeeded     int
+		Sources       int
+		MinSuccessful int
+		Failed        []string
+		Causes        []error
+	}
+)
+
+// compositeSources returns the bootstrap sources configured
+// for the composite bootstrap mode
+func compositeSources(cfg *Ringpop) []namedProvider {
+	var sources []namedProvider
+	if len(cfg.BootstrapHosts) > 0 {
+		sources = append(sources, namedProvider{name: "hosts", provider: newStaticProvider(cfg.BootstrapHosts)})
+	}
+	if len(cfg.BootstrapFile) > 0 {
+		sources = append(sources, namedProvider{name: "file", provider: newFileProvider(cfg.BootstrapFile, cfg.BootstrapFileFormat)})
+	}
+	if len(cfg.BootstrapSystemdCredential) > 0 {
+		sources = append(sources, namedProvider{
+			name:     "systemdcreds",
+			provider: newSystemdCredsProvider(cfg.BootstrapSystemdCredential, cfg.BootstrapFileFormat),
+		})
+	}
+	if len(cfg.BootstrapS3Bucket) > 0 && len(cfg.BootstrapS3Key) > 0 {
+		sources = append(sources, namedProvider{
+			name:     "s3",
+			provider: newS3Provider(cfg.BootstrapS3Bucket, cfg.BootstrapS3Key, cfg.BootstrapS3Region, cfg.BootstrapFileFormat, cfg.MaxJoinDuration),
+		})
+	}
+	if len(cfg.BootstrapNomadJob) > 0 && len(cfg.BootstrapNomadPort) > 0 {
+		sources = append(sources, namedProvider{
+			name:     "nomad",
+			provider: newNomadProvider(cfg.BootstrapNomadAddress, cfg.BootstrapNomadJob, cfg.BootstrapNomadPort, cfg.MaxJoinDuration),
+		})
+	}
+	if len(zkServers(cfg.BootstrapZKConnect)) > 0 && len(cfg.BootstrapZKPath) > 0 {
+		sources = append(sources, namedProvider{
+			name:     "zk",
+			provider: newZKProvider(zkServers(cfg.BootstrapZKConnect), cfg.BootstrapZKPath, cfg.BootstrapZKReadData, cfg.BootstrapFileFormat, cfg.MaxJoinDuration),
+		})
+	}
+	if len(cfg.BootstrapK8sNamespace) > 0 && len(cfg.BootstrapK8sSelector) > 0 {
+		sources = append(sources, namedProvider{
+			name:     "k8spods",
+			provider: newK8sPodsProvider(cfg.BootstrapK8sNamespace, cfg.BootstrapK8sSelector, cfg.BootstrapK8sPort, cfg.MaxJoinDuration),
+		})
+	}
+	if len(cfg.BootstrapEtcdEndpoints) > 0 && len(cfg.BootstrapEtcdPrefix) > 0 {
+		sources = append(sources, namedProvider{
+			name:     "etcd",
+			provider: newEtcdProvider(cfg.BootstrapEtcdEndpoints, cfg.BootstrapEtcdPrefix, cfg.BootstrapEtcdRequireLease, cfg.MaxJoinDuration),
+		})
+	}
+	if validateVaultConfig(cfg.BootstrapVaultAddress, cfg.BootstrapVaultPath, cfg.BootstrapVaultField) == nil {
+		sources = append(sources, namedProvider{
+			name:     "vault",
+			provider: newVaultProvider(cfg.BootstrapVaultAddress, cfg.BootstrapVaultPath, cfg.BootstrapVaultField, cfg.BootstrapFileFormat, cfg.MaxJoinDuration),
+		})
+	}
+	if cfg.DiscoveryProvider != nil {
+		sources = append(sources, namedProvider{name: "custom", provider: cfg.DiscoveryProvider})
+	}
+	return sources
+}
+
+func newCompositeProvider(minSuccessful int, sources []namedProvider) *compositeProvider {
+	return &compositeProvider{
+		minSuccessful: minSuccessful,
+		sources:       sources,
+	}
+}
+
+// Hosts implements discovery.DiscoverProvider
+func (p *compositeProvider) Hosts() ([]string, error) {
+	results := make([][]string, len(p.sources))
+	errs := make([]error, len(p.sources))
+
+	var wg sync.WaitGroup
+	wg.Add(len(p.sources))
+	for i := range p.sources {
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = p.sources[i].provider.Hosts()
+			if errs[i] == nil && len(results[i]) == 0 {
+				errs[i] = errors.New("no hosts found")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var hosts []string
+	compositeErr := &CompositeDiscoveryError{Sources: len(p.sources), MinSuccessful: p.minSuccessful}
+	for i, src := range p.sources {
+		if errs[i] != nil {
+			compositeErr.Failed = append(compositeErr.Failed, src.name)
+			compositeErr.Causes = append(compositeErr.Causes, errs[i])
+			continue
+		}
+		hosts = append(hosts, results[i]...)
+	}
+
+	compositeErr.Succeeded = len(p.sources) - len(compositeErr.Failed)
+	if compositeErr.Succeeded < p.minSuccessful {
+		return nil, compositeErr
+	}
+	return dedupHosts(hosts), nil
+}
+
+// Error implements error
+func (e *CompositeDiscoveryError) Error() string {
+	failures := make([]string, len(e.Failed))
+	for i, name := range e.Failed {
+		failures[i] = fmt.Sprintf("%v: %v", name, e.Causes[i])
+	}
+	return fmt.Sprintf("ringpop composite discovery: %v of %v sources succeeded, %v required: %v",
+		e.Succeeded, e.Sources, e.MinSuccessful, strings.Join(failures, "; "))
+}
+
+// hostsWithContext calls the provider, which doesn't take a context,
+// in a separate goroutine and returns early once the context is done.
+// The result of an abandoned call is dropped when it eventually returns.
+func hostsWithContext(ctx context.Context, provider discovery.DiscoverProvider) ([]string, error) {
+	return hostsWithRelease(ctx, provider, func() {})
+}
+
+// hostsWithRelease is like hostsWithContext, except that release is
+// called once the provider returns, even when the call was abandoned,
+// so that what the call holds is only released when it is done
+func hostsWithRelease(ctx context.Context, provider discovery.DiscoverProvider, release func()) ([]string, error) {
+	type result struct {
+		hosts []string
+		err   error
+	}
+	// buffered so that an abandoned call never blocks on send
+	resultC := make(chan result, 1)
+	go func() {
+		defer release()
+		hosts, err := provider.Hosts()
+		resultC <- result{hosts: hosts, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-resultC:
+		return r.hosts, r.err
+	}
+}
+
+// discoveryProvider returns the discovery provider for the factory
+// config, with the outcome of its sources logged, the seed snapshot as
+// a fallback, the factory's seed list steps applied to the discovered
+// seeds, rate limited when a discovery qps is configured and guarded
+// by the discovery circuit breaker when one is configured
+func (factory *RingpopFactory) discoveryProvider() (discovery.DiscoverProvider, error) {
+	source, err := newSourceDiscoveryProvider(factory.sourceConfig())
+	if err != nil {
+		return nil, err
+	}
+	if blend, ok := source.(*blendProvider); ok {
+		blend.logger = factory.bootstrapLogger()
+	}
+	provider := newSeedListProvider(factory.withSnapshotFallback(factory.withSourceOutcomes(source)), seedListSteps(factory.config))
+	var steps []seedListStep
+	if factory.config.BootstrapMode == BootstrapModeFile && factory.config.BootstrapFileStaleness > 0 {
+		steps = append(steps, factory.checkFileStaleness)
+	}
+	if factory.config.FilterNonRoutableSeeds {
+		steps = append(steps, factory.filterNonRoutableSeeds)
+	}
+	if factory.addressTransform != nil {
+		steps = append(steps, factory.transformHosts)
+	}
+	if factory.seedScorer != nil {
+		steps = append(steps, factory.scoreSeeds)
+	}
+	if factory.seedListTransform != nil {
+		steps = append(steps, factory.transformSeedList)
+	}
+	if factory.seedValidator != nil {
+		steps = append(steps, factory.validateSeeds)
+	}
+	provider = newSeedListProvider(provider, steps)
+	if factory.discoveryLimiter != nil {
+		provider = &rateLimitedProvider{
+			provider: provider,
+			limiter:  factory.discoveryLimiter,
+			clock:    factory.clock,
+			timeout:  factory.config.MaxJoinDuration,
+		}
+	}
+	if factory.discoveryBreaker != nil {
+		provider = &breakerProvider{provider: provider, breaker: factory.discoveryBreaker}
+	}
+	return provider, nil
+}
+
+// scoreSeeds sorts the seeds by descending score of the seed scorer
+func (factory *RingpopFactory) scoreSeeds(hosts []string) ([]string, error) {
+	scores := make(map[string]float64, len(hosts))
+	for _, host := range hosts {
+		scores[host] = factory.seedScorer(host)
+	}
+	sorted := append([]string(nil), hosts...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return scores[sorted[i]] > scores[sorted[j]]
+	})
+	return sorted, nil
+}
+
+// transformSeedList applies the seed list transform to the seeds
+func (factory *RingpopFactory) transformSeedList(hosts []string) ([]string, error) {
+	transformed, err := factory.seedListTransform(hosts)
+	if err != nil {
+		return nil, fmt.Errorf("ringpop seed list transform failed on %v seeds: %v", len(hosts), err)
+	}
+	return transformed, nil
+}
+
+// validateSeeds runs the seed validator on the seeds
+func (factory *RingpopFactory) validateSeeds(hosts []string) ([]string, error) {
+	if err := factory.seedValidator(hosts); err != nil {
+		return nil, fmt.Errorf("ringpop seed validation failed on %v seeds: %v", len(hosts), err)
+	}
+	return hosts, nil
+}
+
+// seedListSteps returns the post-processing steps enabled by the config
+func seedListSteps(cfg *Ringpop) []seedListStep {
+	var steps []seedListStep
+	if mode := strings.ToLower(cfg.AddressNormalization); mode != "" && mode != addressNormalizationNone {
+		steps = append(steps, func(hosts []string) ([]string, error) {
+			return dedupHosts(normalizeHosts(hosts, mode)), nil
+		})
+	}
+	if len(cfg.BootstrapHostDenyList) > 0 {
+		resolver := resolverOrDefault(cfg.Resolver)
+		steps = append(steps, denyListStep(cfg.BootstrapHostDenyList, func(host string) ([]string, error) {
+			return resolver.LookupHost(context.Background(), host)
+		}))
+	}
+	return steps
+}
+
+// denyListStep returns a step filtering out the denied seeds. Seeds
+// and denied entries are compared by address, so a hostname matches
+// a denied ip and vice versa. Failed lookups leave the entry as is
+func denyListStep(denyList []string, lookup func(host string) ([]string, error)) seedListStep {
+	return func(hosts []string) ([]string, error) {
+		denied := make(map[string]struct{})
+		for _, host := range denyList {
+			for _, addr := range resolveHostPort(host, lookup) {
+				denied[addr] = struct{}{}
+			}
+		}
+		var allowed []string
+	seeds:
+		for _, host := range hosts {
+			for _, addr := range resolveHostPort(host, lookup) {
+				if _, ok := denied[addr]; ok {
+					continue seeds
+				}
+			}
+			allowed = append(allowed, host)
+		}
+		if len(allowed) == 0 {
+			return nil, fmt.Errorf("ringpop config `bootstrapHostDenyList` denies all of the %v discovered seeds", len(hosts))
+		}
+		return allowed, nil
+	}
+}
+
+// filterNonRoutableSeeds drops the loopback, link-local and unspecified
+// seeds, logging each of them. Hostnames are left as is. The bootstrap
+// fails, like for any discovery error, when no seed is left
+func (factory *RingpopFactory) filterNonRoutableSeeds(hosts []string) ([]string, error) {
+	var routable []string
+	for _, host := range hosts {
+		if isNonRoutableHost(host) {
+			factory.bootstrapLogger().WithField("seed", host).Warn("Ringpop dropped a non routable seed")
+			continue
+		}
+		routable = append(routable, host)
+	}
+	if len(routable) == 0 && len(hosts) > 0 {
+		return nil, fmt.Errorf("ringpop config `filterNonRoutableSeeds` dropped all of the %v discovered seeds", len(hosts))
+	}
+	return routable, nil
+}
+
+func isNonRoutableHost(hostPort string) bool {
+	host, _, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		host = hostPort
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified()
+}
+
+// resolveHostPort returns the normalized host:port along with
+// the ip:port it resolves to when the host is a hostname
+func resolveHostPort(hostPort string, lookup func(host string) ([]string, error)) []string {
+	addrs := []string{normalizeHost(hostPort, addressNormalizationIPv4)}
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil || net.ParseIP(host) != nil {
+		return addrs
+	}
+	ips, err := lookup(host)
+	if err != nil {
+		return addrs
+	}
+	for _, ip := range ips {
+		addrs = append(addrs, normalizeHost(net.JoinHostPort(ip, port), addressNormalizationIPv4))
+	}
+	return addrs
+}
+
+func newSeedListProvider(provider discovery.DiscoverProvider, steps []seedListStep) discovery.DiscoverProvider {
+	if len(steps) == 0 {
+		return provider
+	}
+	return &seedListProvider{
+		provider: provider,
+		steps:    steps,
+	}
+}
+
+// Hosts implements discovery.DiscoverProvider
+func (p *seedListProvider) Hosts() ([]string, error) {
+	hosts, err := p.provider.Hosts()
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range p.steps {
+		if hosts, err = step(hosts); err != nil {
+			return nil, err
+		}
+	}
+	return hosts, nil
+}
+
+func validateAddressNormalization(mode string) error {
+	switch strings.ToLower(mode) {
+	case "", addressNormalizationNone, addressNormalizationIPv4, addressNormalizationIPv6:
+		return nil
+	}
+	return fmt.Errorf("ringpop config `addressNormalization` must be one of ipv4, ipv6 or none, got %q", mode)
+}
+
+// normalizeHosts canonicalizes the IP of each host:port according to mode,
+// collapsing IPv4-mapped IPv6 addresses to IPv4 or expanding IPv4 addresses
+// to their IPv4-mapped IPv6 form. Hostnames are left untouched.
+func normalizeHosts(hosts []string, mode string) []string {
+	result := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		result = append(result, normalizeHost(host, mode))
+	}
+	return result
+}
+
+func normalizeHost(hostPort string, mode string) string {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		// not a host:port, it may still be a bare ip
+		host, port = hostPort, ""
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return hostPort
+	}
+	switch mode {
+	case addressNormalizationIPv4:
+		host = ip.String()
+	case addressNormalizationIPv6:
+		if ip4 := ip.To4(); ip4 != nil {
+			host = "::ffff:" + ip4.String()
+		} else {
+			host = ip.String()
+		}
+	}
+	if len(port) == 0 {
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// dedupHosts removes duplicate hosts, preserving their order
+func dedupHosts(hosts []string) []string {
+	result := make([]string, 0, len(hosts))
+	seen := make(map[string]struct{}, len(hosts))
+	for _, host := range hosts {
+		if _, ok := seen[host]; !ok {
+			seen[host] = struct{}{}
+			result = append(result, host)
+		}
+	}
+	return result
+}
@@ -0,0 +1,1089 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/samuel/go-zookeeper/zk"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/uber/ringpop-go/discovery"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+type (
+	RingpopDiscoverySuite struct {
+		*require.Assertions
+		suite.Suite
+	}
+
+	// slowProvider blocks on Hosts until it is released
+	slowProvider struct {
+		hosts    []string
+		startedC chan struct{}
+		releaseC chan struct{}
+	}
+)
+
+func newSlowProvider(hosts ...string) *slowProvider {
+	return &slowProvider{
+		hosts:    hosts,
+		startedC: make(chan struct{}),
+		releaseC: make(chan struct{}),
+	}
+}
+
+func (p *slowProvider) Hosts() ([]string, error) {
+	close(p.startedC)
+	<-p.releaseC
+	return p.hosts, nil
+}
+
+func TestRingpopDiscoverySuite(t *testing.T) {
+	suite.Run(t, new(RingpopDiscoverySuite))
+}
+
+func (s *RingpopDiscoverySuite) SetupTest() {
+	s.Assertions = require.New(s.T())
+}
+
+func (s *RingpopDiscoverySuite) TestNormalizeHosts() {
+	hosts := []string{"[::ffff:10.0.0.1]:7933", "10.0.0.1:7933", "::ffff:10.0.0.2", "[2001:db8::1]:7933", "cadence-0:7933", "10.0.0.3"}
+	s.Equal([]string{"10.0.0.1:7933", "10.0.0.1:7933", "10.0.0.2", "[2001:db8::1]:7933", "cadence-0:7933", "10.0.0.3"},
+		normalizeHosts(hosts, addressNormalizationIPv4))
+	s.Equal([]string{"[::ffff:10.0.0.1]:7933", "[::ffff:10.0.0.1]:7933", "::ffff:10.0.0.2", "[2001:db8::1]:7933", "cadence-0:7933", "::ffff:10.0.0.3"},
+		normalizeHosts(hosts, addressNormalizationIPv6))
+}
+
+func (s *RingpopDiscoverySuite) TestAddressNormalizationProvider() {
+	cfg := &Ringpop{
+		Name:                 "test",
+		BootstrapMode:        BootstrapModeHosts,
+		BootstrapHosts:       []string{"[::ffff:10.0.0.1]:7933", "10.0.0.1:7933", "10.0.0.2:7933"},
+		AddressNormalization: "IPv4",
+	}
+	f, err := NewFactory(cfg)
+	s.Nil(err)
+	provider, err := f.discoveryProvider()
+	s.Nil(err)
+	hosts, err := provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933", "10.0.0.2:7933"}, hosts)
+
+	cfg.AddressNormalization = "none"
+	provider, err = f.discoveryProvider()
+	s.Nil(err)
+	hosts, err = provider.Hosts()
+	s.Nil(err)
+	s.Equal(cfg.BootstrapHosts, hosts)
+
+	cfg.AddressNormalization = "ipv5"
+	s.NotNil(cfg.validate())
+}
+
+func (s *RingpopDiscoverySuite) TestDenyList() {
+	lookup := func(host string) ([]string, error) {
+		switch host {
+		case "cadence-bad":
+			return []string{"10.0.0.2"}, nil
+		case "cadence-good":
+			return []string{"10.0.0.3"}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	step := denyListStep([]string{"[::ffff:10.0.0.1]:7933", "cadence-bad:7933"}, lookup)
+	hosts, err := step([]string{"10.0.0.1:7933", "10.0.0.2:7933", "cadence-good:7933", "cadence-other:7933", "10.0.0.2:7934"})
+	s.Nil(err)
+	s.Equal([]string{"cadence-good:7933", "cadence-other:7933", "10.0.0.2:7934"}, hosts)
+
+	step = denyListStep([]string{"10.0.0.3:7933"}, lookup)
+	hosts, err = step([]string{"cadence-good:7933"})
+	s.NotNil(err)
+	s.Nil(hosts)
+
+	cfg := &Ringpop{
+		Name:                  "test",
+		BootstrapMode:         BootstrapModeHosts,
+		BootstrapHosts:        []string{"10.0.0.1:7933", "10.0.0.2:7933"},
+		BootstrapHostDenyList: []string{"10.0.0.1:7933"},
+	}
+	f, err := NewFactory(cfg)
+	s.Nil(err)
+	provider, err := f.discoveryProvider()
+	s.Nil(err)
+	hosts, err = provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.2:7933"}, hosts)
+
+	cfg.BootstrapHostDenyList = []string{"10.0.0.1"}
+	s.NotNil(cfg.validate())
+}
+
+func (s *RingpopDiscoverySuite) TestFilterNonRoutableSeeds() {
+	cfg := &Ringpop{
+		Name:                   "test",
+		BootstrapMode:          BootstrapModeHosts,
+		BootstrapHosts:         []string{"127.0.0.1:7933", "10.0.0.1:7933", "[fe80::1]:7933", "0.0.0.0:7933", "cadence-0:7933", "[::1]:7933"},
+		FilterNonRoutableSeeds: true,
+	}
+	f, err := NewFactory(cfg)
+	s.Nil(err)
+	provider, err := f.discoveryProvider()
+	s.Nil(err)
+	hosts, err := provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933", "cadence-0:7933"}, hosts)
+
+	hosts, err = f.filterNonRoutableSeeds([]string{"127.0.0.1:7933", "169.254.0.1:7933"})
+	s.NotNil(err)
+	s.Nil(hosts)
+}
+
+func (s *RingpopDiscoverySuite) TestFileProvider() {
+	file, err := ioutil.TempFile("", "ringpop-bootstrap")
+	s.Nil(err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(`["10.0.0.1:7933", {"address": "10.0.0.2:7933", "zone": "us-west-1a", "tags": ["seed"]}]`)
+	s.Nil(err)
+	s.Nil(file.Close())
+
+	provider := newFileProvider(file.Name(), "")
+	seeds, err := provider.Seeds()
+	s.Nil(err)
+	s.Equal([]Seed{
+		{Address: "10.0.0.1:7933"},
+		{Address: "10.0.0.2:7933", Zone: "us-west-1a", Tags: []string{"seed"}},
+	}, seeds)
+	hosts, err := provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933", "10.0.0.2:7933"}, hosts)
+
+	s.Nil(ioutil.WriteFile(file.Name(), []byte(`[{"zone": "us-west-1a"}]`), 0644))
+	_, err = provider.Hosts()
+	s.NotNil(err)
+	s.Contains(err.Error(), file.Name())
+
+	_, err = newFileProvider("/tmp/does-not-exist.json", "").Hosts()
+	s.NotNil(err)
+}
+
+func (s *RingpopDiscoverySuite) TestFileProviderJSONLines() {
+	file, err := ioutil.TempFile("", "ringpop-bootstrap")
+	s.Nil(err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString("{\"address\": \"10.0.0.1:7933\"}\n\n  \n{\"address\": \"10.0.0.2:7933\", \"zone\": \"us-west-1a\"}\n")
+	s.Nil(err)
+	s.Nil(file.Close())
+
+	provider := newFileProvider(file.Name(), "JSONL")
+	seeds, err := provider.Seeds()
+	s.Nil(err)
+	s.Equal([]Seed{
+		{Address: "10.0.0.1:7933"},
+		{Address: "10.0.0.2:7933", Zone: "us-west-1a"},
+	}, seeds)
+
+	s.Nil(ioutil.WriteFile(file.Name(), []byte("{\"address\": \"10.0.0.1:7933\"}\n\n{\"address\": \n"), 0644))
+	_, err = provider.Hosts()
+	s.NotNil(err)
+	s.Contains(err.Error(), file.Name())
+	s.Contains(err.Error(), "line 3")
+
+	cfg := &Ringpop{Name: "test", BootstrapMode: BootstrapModeFile, BootstrapFile: file.Name(), BootstrapFileFormat: "jsonl"}
+	s.Nil(cfg.validate())
+	cfg.BootstrapFileFormat = "yaml"
+	s.NotNil(cfg.validate())
+}
+
+func (s *RingpopDiscoverySuite) TestNewStaticProvider() {
+	defer func(original func([]string) discovery.DiscoverProvider) { newStaticProvider = original }(newStaticProvider)
+	var built [][]string
+	newStaticProvider = func(hosts []string) discovery.DiscoverProvider {
+		built = append(built, hosts)
+		return &flakyProvider{hosts: []string{"10.0.0.9:7933"}}
+	}
+
+	cfg := &Ringpop{Name: "test", BootstrapMode: BootstrapModeHosts, BootstrapHosts: []string{"10.0.0.1:7933"}}
+	provider, err := newSourceDiscoveryProvider(cfg)
+	s.Nil(err)
+	hosts, err := provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.9:7933"}, hosts)
+	s.Equal([][]string{{"10.0.0.1:7933"}}, built)
+}
+
+func (s *RingpopDiscoverySuite) TestSystemdCredsProvider() {
+	cfg := &Ringpop{Name: "test", BootstrapMode: BootstrapModeSystemdCreds}
+	s.NotNil(cfg.validate())
+	cfg.BootstrapSystemdCredential = "../seeds"
+	s.NotNil(cfg.validate())
+	cfg.BootstrapSystemdCredential = "ringpop-seeds"
+	s.Nil(cfg.validate())
+
+	dir, err := ioutil.TempDir("", "ringpop-creds")
+	s.Nil(err)
+	defer os.RemoveAll(dir)
+	env := map[string]string{}
+	provider := newSystemdCredsProvider("ringpop-seeds", "jsonl")
+	provider.getenv = func(key string) string { return env[key] }
+
+	_, err = provider.Hosts()
+	s.Equal(ErrCredentialsDirectoryNotSet, err)
+
+	env[credentialsDirectoryEnv] = dir
+	_, err = provider.Hosts()
+	s.NotNil(err)
+	s.Contains(err.Error(), `"ringpop-seeds"`)
+
+	s.Nil(ioutil.WriteFile(filepath.Join(dir, "ringpop-seeds"), []byte("\"10.0.0.1:7933\"\n\"10.0.0.2:7933\"\n"), 0400))
+	hosts, err := provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933", "10.0.0.2:7933"}, hosts)
+}
+
+func (s *RingpopDiscoverySuite) TestBlendProvider() {
+	file, err := ioutil.TempFile("", "ringpop-bootstrap")
+	s.Nil(err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(`["10.0.0.2:7933", "10.0.0.1:7933"]`)
+	s.Nil(err)
+	s.Nil(file.Close())
+
+	cfg := &Ringpop{Name: "test", BootstrapMode: BootstrapModeBlend, BlendDynamicMode: BootstrapModeCustom}
+	s.NotNil(cfg.validate())
+	cfg.BootstrapFile = file.Name()
+	s.NotNil(cfg.validate())
+	dynamic := &flakyProvider{hosts: []string{"10.0.0.1:7933", "10.0.0.3:7933", "10.0.0.4:7933", "10.0.0.5:7933"}}
+	cfg.DiscoveryProvider = dynamic
+	s.Nil(cfg.validate())
+	cfg.BlendDynamicMode = BootstrapModeHosts
+	s.NotNil(cfg.validate())
+	cfg.BlendDynamicMode = BootstrapModeCustom
+	cfg.MaxDynamicSeeds = -1
+	s.NotNil(cfg.validate())
+	cfg.MaxDynamicSeeds = 2
+
+	provider, err := newSourceDiscoveryProvider(cfg)
+	s.Nil(err)
+	hosts, err := provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.2:7933", "10.0.0.1:7933", "10.0.0.3:7933", "10.0.0.4:7933"}, hosts)
+
+	// a failed dynamic source is logged and leaves the file seeds alone
+	dynamic.fail = true
+	hosts, err = provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.2:7933", "10.0.0.1:7933"}, hosts)
+	f, err := NewFactory(cfg)
+	s.Nil(err)
+	provider, err = f.discoveryProvider()
+	s.Nil(err)
+	hosts, err = provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.2:7933", "10.0.0.1:7933"}, hosts)
+
+	// the custom provider is left out when another mode is the dynamic source
+	cfg.BlendDynamicMode = BootstrapModeDNS
+	cfg.BootstrapHosts = []string{"cadence:7933"}
+	s.Nil(cfg.validate())
+	blend, err := newBlendProvider(cfg)
+	s.Nil(err)
+	s.IsType(&dnsProvider{}, blend.dynamic.provider)
+}
+
+func (s *RingpopDiscoverySuite) TestMmapProvider() {
+	cfg := &Ringpop{Name: "test", BootstrapMode: BootstrapModeMmap}
+	s.NotNil(cfg.validate())
+
+	file, err := ioutil.TempFile("", "ringpop-bootstrap")
+	s.Nil(err)
+	defer os.Remove(file.Name())
+	s.Nil(file.Close())
+	cfg.BootstrapFile = file.Name()
+	s.Nil(cfg.validate())
+
+	provider, err := newSourceDiscoveryProvider(cfg)
+	s.Nil(err)
+	_, err = provider.Hosts()
+	s.NotNil(err)
+	s.Contains(err.Error(), "empty")
+
+	s.Nil(ioutil.WriteFile(file.Name(), []byte(`["10.0.0.1:7933"]`), 0644))
+	hosts, err := provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933"}, hosts)
+
+	// the file grows and then shrinks between reads
+	s.Nil(ioutil.WriteFile(file.Name(), []byte(`["10.0.0.1:7933", "10.0.0.2:7933", "10.0.0.3:7933"]`), 0644))
+	hosts, err = provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933", "10.0.0.2:7933", "10.0.0.3:7933"}, hosts)
+	s.Nil(ioutil.WriteFile(file.Name(), []byte(`["10.0.0.2:7933"]`), 0644))
+	hosts, err = provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.2:7933"}, hosts)
+
+	// the file is only parsed again once its size or modification time changes
+	info, err := os.Stat(file.Name())
+	s.Nil(err)
+	s.Nil(ioutil.WriteFile(file.Name(), []byte(`["10.0.0.4:7933"]`), 0644))
+	s.Nil(os.Chtimes(file.Name(), info.ModTime(), info.ModTime()))
+	hosts, err = provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.2:7933"}, hosts)
+	s.Nil(os.Chtimes(file.Name(), info.ModTime().Add(time.Second), info.ModTime().Add(time.Second)))
+	hosts, err = provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.4:7933"}, hosts)
+
+	// and may be gzip compressed, like a plain bootstrap file
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	_, err = writer.Write([]byte(`["10.0.0.5:7933"]`))
+	s.Nil(err)
+	s.Nil(writer.Close())
+	s.Nil(ioutil.WriteFile(file.Name(), compressed.Bytes(), 0644))
+	hosts, err = provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.5:7933"}, hosts)
+
+	s.Nil(ioutil.WriteFile(file.Name(), []byte(`["10.0.0.2:7933"`), 0644))
+	_, err = provider.Hosts()
+	s.NotNil(err)
+	s.Contains(err.Error(), file.Name())
+
+	s.Nil(os.Remove(file.Name()))
+	_, err = provider.Hosts()
+	s.NotNil(err)
+	s.Contains(err.Error(), file.Name())
+}
+
+func (s *RingpopDiscoverySuite) TestMmapTruncated() {
+	if runtime.GOOS == "windows" {
+		s.T().Skip("a memory mapped file can't be truncated on windows")
+	}
+	file, err := ioutil.TempFile("", "ringpop-bootstrap")
+	s.Nil(err)
+	defer os.Remove(file.Name())
+	defer file.Close()
+	size := 4 * os.Getpagesize()
+	_, err = file.Write(make([]byte, size))
+	s.Nil(err)
+
+	data, unmap, err := mmapFile(file, size)
+	s.Nil(err)
+	defer unmap()
+	buf, err := copyMapping(data)
+	s.Nil(err)
+	s.Len(buf, size)
+
+	// reading past the end of the truncated file faults instead of crashing
+	s.Nil(file.Truncate(0))
+	_, err = copyMapping(data)
+	s.NotNil(err)
+}
+
+func (s *RingpopDiscoverySuite) TestS3Provider() {
+	cfg := &Ringpop{Name: "test", BootstrapMode: BootstrapModeS3, BootstrapS3Bucket: "cadence"}
+	s.NotNil(cfg.validate())
+	cfg.BootstrapS3Key = "seeds.json"
+	s.Nil(cfg.validate())
+
+	provider := newS3Provider("cadence", "seeds.json", "us-east-1", "", time.Second)
+	provider.fetch = func(ctx context.Context, region string, bucket string, key string) ([]byte, error) {
+		_, hasDeadline := ctx.Deadline()
+		s.True(hasDeadline)
+		s.Equal("us-east-1", region)
+		s.Equal("cadence", bucket)
+		s.Equal("seeds.json", key)
+		return []byte(`["10.0.0.1:7933", {"address": "10.0.0.2:7933", "zone": "us-east-1a"}]`), nil
+	}
+	hosts, err := provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933", "10.0.0.2:7933"}, hosts)
+
+	provider.fetch = func(ctx context.Context, region string, bucket string, key string) ([]byte, error) {
+		return nil, errors.New("access denied")
+	}
+	_, err = provider.Hosts()
+	s.NotNil(err)
+	s.Contains(err.Error(), "s3://cadence/seeds.json")
+
+	provider.fetch = func(ctx context.Context, region string, bucket string, key string) ([]byte, error) {
+		return []byte(`{`), nil
+	}
+	_, err = provider.Hosts()
+	s.NotNil(err)
+	s.Contains(err.Error(), "s3://cadence/seeds.json")
+
+	provider = newS3Provider("cadence", "seeds.jsonl", "us-east-1", bootstrapFileFormatJSONL, time.Second)
+	provider.fetch = func(ctx context.Context, region string, bucket string, key string) ([]byte, error) {
+		return []byte("\"10.0.0.1:7933\"\n\n{\"address\": \"10.0.0.2:7933\", \"zone\": \"us-east-1a\"}\n"), nil
+	}
+	seeds, err := provider.Seeds()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933", "10.0.0.2:7933"}, seedAddresses(seeds))
+	s.Equal("us-east-1a", seeds[1].Zone)
+}
+
+func (s *RingpopDiscoverySuite) TestVaultProvider() {
+	cfg := &Ringpop{Name: "test", BootstrapMode: BootstrapModeVault, BootstrapVaultPath: "secret/data/cadence", BootstrapVaultField: "seeds"}
+	s.NotNil(cfg.validate())
+	cfg.BootstrapVaultAddress = "vault:8200"
+	s.NotNil(cfg.validate())
+	cfg.BootstrapVaultAddress = "https://vault:8200"
+	s.Nil(cfg.validate())
+	cfg.BootstrapVaultField = ""
+	s.NotNil(cfg.validate())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors": ["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/cadence":
+			w.Write([]byte(`{"data": {"data": {"seeds": "[\"10.0.0.1:7933\", {\"address\": \"10.0.0.2:7933\"}]"}, "metadata": {"version": 3}}}`))
+		case "/v1/kv/cadence":
+			w.Write([]byte(`{"data": {"seeds": ["10.0.0.3:7933"], "other": "x"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors": []}`))
+		}
+	}))
+	defer server.Close()
+
+	provider := newVaultProvider(server.URL+"/", "/secret/data/cadence", "seeds", "", time.Second)
+	provider.token = "secret"
+	hosts, err := provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933", "10.0.0.2:7933"}, hosts)
+
+	// kv version 1 secret, with the seed list stored as json
+	provider.path = "kv/cadence"
+	hosts, err = provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.3:7933"}, hosts)
+
+	provider.field = "other"
+	_, err = provider.Hosts()
+	s.NotNil(err)
+	s.Contains(err.Error(), "kv/cadence")
+	provider.field = "missing"
+	_, err = provider.Hosts()
+	s.NotNil(err)
+	s.Contains(err.Error(), "no field")
+
+	provider.path = "secret/data/matching"
+	_, err = provider.Hosts()
+	s.NotNil(err)
+	s.Contains(err.Error(), "secret/data/matching")
+
+	provider.token = ""
+	_, err = provider.Hosts()
+	s.NotNil(err)
+	s.Contains(err.Error(), "secret/data/matching")
+	s.Contains(err.Error(), "permission denied")
+}
+
+func (s *RingpopDiscoverySuite) TestNomadProvider() {
+	cfg := &Ringpop{Name: "test", BootstrapMode: BootstrapModeNomad, BootstrapNomadPort: "ringpop"}
+	s.NotNil(cfg.validate())
+	cfg.BootstrapNomadJob = "cadence"
+	s.Nil(cfg.validate())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal("secret", r.Header.Get("X-Nomad-Token"))
+		switch r.URL.Path {
+		case "/v1/job/cadence/allocations":
+			w.Write([]byte(`[
+				{"ID": "a1", "ClientStatus": "running"},
+				{"ID": "a2", "ClientStatus": "running", "DeploymentStatus": {"Healthy": true}},
+				{"ID": "a3", "ClientStatus": "running", "DeploymentStatus": {"Healthy": false}},
+				{"ID": "a4", "ClientStatus": "complete"},
+				{"ID": "a5", "ClientStatus": "running"}
+			]`))
+		case "/v1/allocation/a1":
+			w.Write([]byte(`{"Resources": {"Networks": [{"IP": "10.0.0.1", "DynamicPorts": [{"Label": "ringpop", "Value": 7933}]}]}}`))
+		case "/v1/allocation/a2":
+			w.Write([]byte(`{"AllocatedResources": {"Shared": {"Networks": [{"IP": "10.0.0.2", "ReservedPorts": [{"Label": "ringpop", "Value": 7934}]}]}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	provider := newNomadProvider(server.URL, "cadence", "ringpop", time.Second)
+	provider.token = "secret"
+	hosts, err := provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933", "10.0.0.2:7934"}, hosts)
+
+	provider.port = "8000"
+	hosts, err = provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:8000", "10.0.0.2:8000"}, hosts)
+
+	provider.job = "matching"
+	_, err = provider.Hosts()
+	s.NotNil(err)
+	s.Contains(err.Error(), "job matching")
+
+	// the timeout bounds the whole call rather than each request
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/job/cadence/allocations" {
+			w.Write([]byte(`[{"ID": "a1", "ClientStatus": "running"}, {"ID": "a2", "ClientStatus": "running"}, {"ID": "a3", "ClientStatus": "running"}]`))
+			return
+		}
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer slow.Close()
+	provider = newNomadProvider(slow.URL, "cadence", "ringpop", 200*time.Millisecond)
+	start := time.Now()
+	_, err = provider.Hosts()
+	s.NotNil(err)
+	s.Contains(err.Error(), "a1, a2, a3")
+	s.True(time.Since(start) < 600*time.Millisecond)
+}
+
+func (s *RingpopDiscoverySuite) TestPeerProvider() {
+	cfg := &Ringpop{Name: "test", BootstrapMode: BootstrapModePeer}
+	s.NotNil(cfg.validate())
+	cfg.BootstrapPeer = "10.0.0.1"
+	s.NotNil(cfg.validate())
+	cfg.BootstrapPeer = "10.0.0.1:7933"
+	s.Nil(cfg.validate())
+
+	var stats peerStats
+	err := json.Unmarshal([]byte(`{"membership": {"checksum": 1, "members": [
+		{"address": "10.0.0.1:7933", "status": "alive", "incarnationNumber": 1},
+		{"address": "10.0.0.2:7933", "status": "faulty", "incarnationNumber": 1},
+		{"address": "10.0.0.3:7933", "status": "alive", "incarnationNumber": 2}
+	]}}`), &stats)
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933", "10.0.0.3:7933"}, stats.aliveMembers(""))
+}
+
+func (s *RingpopDiscoverySuite) TestFrontendProvider() {
+	cfg := &Ringpop{Name: "test", BootstrapMode: BootstrapModeFrontend, BootstrapFrontendService: "cadence-history"}
+	s.NotNil(cfg.validate())
+	cfg.BootstrapFrontendAddress = "10.0.0.1:7933"
+	cfg.BootstrapFrontendService = ""
+	s.NotNil(cfg.validate())
+	cfg.BootstrapFrontendService = "cadence-history"
+	s.Nil(cfg.validate())
+
+	var stats peerStats
+	err := json.Unmarshal([]byte(`{"membership": {"checksum": 1, "members": [
+		{"address": "10.0.0.1:7933", "status": "alive", "labels": {"serviceName": "cadence-frontend"}},
+		{"address": "10.0.0.2:7934", "status": "alive", "labels": {"serviceName": "cadence-history"}},
+		{"address": "10.0.0.3:7934", "status": "suspect", "labels": {"serviceName": "cadence-history"}},
+		{"address": "10.0.0.4:7934", "status": "alive"}
+	]}}`), &stats)
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.2:7934"}, stats.aliveMembers("cadence-history"))
+}
+
+func (s *RingpopDiscoverySuite) TestHostsWithContext() {
+	provider := newSlowProvider("10.0.0.1:7933")
+	close(provider.releaseC)
+	hosts, err := hostsWithContext(context.Background(), provider)
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933"}, hosts)
+}
+
+func (s *RingpopDiscoverySuite) TestHostsWithContextCancelled() {
+	provider := newSlowProvider("10.0.0.1:7933")
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-provider.startedC
+		cancel()
+	}()
+	released := make(chan struct{})
+	_, err := hostsWithRelease(ctx, provider, func() { close(released) })
+	s.Equal(context.Canceled, err)
+	// the abandoned call must be able to complete without a reader,
+	// and only releases what it holds once it does
+	releasedEarly := false
+	select {
+	case <-released:
+		releasedEarly = true
+	default:
+	}
+	s.False(releasedEarly)
+	close(provider.releaseC)
+	<-released
+
+	provider = newSlowProvider()
+	defer close(provider.releaseC)
+	cfg := &Ringpop{Name: "test", BootstrapMode: BootstrapModeCustom, DiscoveryProvider: provider}
+	f, err := NewFactory(cfg)
+	s.Nil(err)
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = f.ResolveAndPrepareContext(ctx, nil)
+	s.Equal(context.Canceled, err)
+}
+
+func (s *RingpopDiscoverySuite) TestSourceOutcomes() {
+	var outcomes []sourceOutcome
+	provider := &outcomeProvider{
+		name:     "file",
+		provider: newFileProvider("/does/not/exist", ""),
+		clock:    newFakeClock(),
+		report:   func(outcome sourceOutcome) { outcomes = append(outcomes, outcome) },
+	}
+	_, err := provider.Hosts()
+	s.NotNil(err)
+	s.Len(outcomes, 1)
+	s.Equal("file", outcomes[0].source)
+	s.Equal(0, outcomes[0].hosts)
+	s.NotNil(outcomes[0].err)
+
+	cfg := &Ringpop{
+		Name:                          "test",
+		BootstrapMode:                 BootstrapModeComposite,
+		BootstrapHosts:                []string{"10.0.0.1:7933", "10.0.0.2:7933"},
+		BootstrapFile:                 "/does/not/exist",
+		CompositeMinSuccessfulSources: 1,
+	}
+	f, err := NewFactory(cfg)
+	s.Nil(err)
+	composite, err := newSourceDiscoveryProvider(cfg)
+	s.Nil(err)
+	f.withSourceOutcomes(composite)
+	for _, src := range composite.(*compositeProvider).sources {
+		s.IsType(&outcomeProvider{}, src.provider)
+	}
+	hosts, err := composite.Hosts()
+	s.Nil(err)
+	s.Equal(cfg.BootstrapHosts, hosts)
+	s.Equal(uint64(1), f.discoveryAttempts)
+
+	cfg.BootstrapMode = BootstrapModeHosts
+	f, err = NewFactory(cfg)
+	s.Nil(err)
+	single := f.withSourceOutcomes(newFileProvider("/does/not/exist", ""))
+	s.Equal("hosts", single.(*outcomeProvider).name)
+}
+
+func (s *RingpopDiscoverySuite) TestZKProvider() {
+	cfg := &Ringpop{Name: "test", BootstrapMode: BootstrapModeZK, BootstrapZKPath: "/cadence/ringpop"}
+	s.NotNil(cfg.validate())
+	cfg.BootstrapZKConnect = "zk1:2181, zk2:2181"
+	s.Nil(cfg.validate())
+	cfg.BootstrapZKPath = "cadence/ringpop"
+	s.NotNil(cfg.validate())
+	s.Equal([]string{"zk1:2181", "zk2:2181"}, zkServers(cfg.BootstrapZKConnect))
+
+	provider := newZKProvider([]string{"zk1:2181"}, "/cadence/ringpop", false, "", time.Second)
+	provider.fetch = func(servers []string, path string, timeout time.Duration) (*zkNode, error) {
+		s.Equal([]string{"zk1:2181"}, servers)
+		s.Equal("/cadence/ringpop", path)
+		s.Equal(time.Second, timeout)
+		return &zkNode{
+			data:     []byte(`["10.0.0.3:7933"]`),
+			children: []string{"10.0.0.1:7933", "10.0.0.2:7933"},
+		}, nil
+	}
+	hosts, err := provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933", "10.0.0.2:7933"}, hosts)
+
+	provider.readData = true
+	hosts, err = provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.3:7933"}, hosts)
+
+	// the data has the bootstrap file format
+	fetch := provider.fetch
+	provider.fetch = func(servers []string, path string, timeout time.Duration) (*zkNode, error) {
+		return &zkNode{data: []byte("{\"address\": \"10.0.0.4:7933\"}\n\n{\"address\": \"10.0.0.5:7933\"}\n")}, nil
+	}
+	_, err = provider.Hosts()
+	s.NotNil(err)
+	provider.format = bootstrapFileFormatJSONL
+	hosts, err = provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.4:7933", "10.0.0.5:7933"}, hosts)
+	provider.fetch = fetch
+
+	provider.readData = false
+	provider.fetch = func(servers []string, path string, timeout time.Duration) (*zkNode, error) {
+		return &zkNode{children: []string{"lock-0001"}}, nil
+	}
+	_, err = provider.Hosts()
+	s.NotNil(err)
+
+	provider.fetch = func(servers []string, path string, timeout time.Duration) (*zkNode, error) {
+		return nil, zk.ErrNoNode
+	}
+	_, err = provider.Hosts()
+	s.NotNil(err)
+	s.Contains(err.Error(), "does not exist")
+}
+
+func (s *RingpopDiscoverySuite) TestK8sPodsProvider() {
+	cfg := &Ringpop{Name: "test", BootstrapMode: BootstrapModeK8sPods, BootstrapK8sNamespace: "cadence", BootstrapK8sPort: 7933}
+	s.NotNil(cfg.validate())
+	cfg.BootstrapK8sSelector = "app=cadence"
+	s.Nil(cfg.validate())
+	cfg.BootstrapK8sPort = 0
+	s.NotNil(cfg.validate())
+
+	tokenFile, err := ioutil.TempFile("", "token")
+	s.Nil(err)
+	defer os.Remove(tokenFile.Name())
+	_, err = tokenFile.WriteString("secret\n")
+	s.Nil(err)
+	s.Nil(tokenFile.Close())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal("Bearer secret", r.Header.Get("Authorization"))
+		if r.URL.Path != "/api/v1/namespaces/cadence/pods" || r.URL.Query().Get("labelSelector") != "app=cadence" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"items": [
+			{"status": {"phase": "Running", "podIP": "10.0.0.1", "conditions": [{"type": "Ready", "status": "True"}]}},
+			{"status": {"phase": "Running", "podIP": "10.0.0.2", "conditions": [{"type": "Ready", "status": "False"}]}},
+			{"status": {"phase": "Pending", "podIP": "10.0.0.3"}},
+			{"metadata": {"deletionTimestamp": "2018-01-01T00:00:00Z"}, "status": {"phase": "Running", "podIP": "10.0.0.4", "conditions": [{"type": "Ready", "status": "True"}]}},
+			{"status": {"phase": "Running", "podIP": "10.0.0.5", "conditions": [{"type": "Ready", "status": "True"}]}}
+		]}`))
+	}))
+	defer server.Close()
+
+	provider := newK8sPodsProvider("cadence", "app=cadence", 7933, time.Second)
+	provider.apiServer = server.URL
+	provider.tokenFile = tokenFile.Name()
+	provider.client = server.Client()
+	hosts, err := provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933", "10.0.0.5:7933"}, hosts)
+
+	provider.selector = "app=matching"
+	_, err = provider.Hosts()
+	s.NotNil(err)
+	s.Contains(err.Error(), `"app=matching"`)
+}
+
+func (s *RingpopDiscoverySuite) TestEtcdProvider() {
+	cfg := &Ringpop{Name: "test", BootstrapMode: BootstrapModeEtcd, BootstrapEtcdPrefix: "/cadence/ringpop/"}
+	s.NotNil(cfg.validate())
+	cfg.BootstrapEtcdEndpoints = []string{"etcd-0:2379"}
+	s.NotNil(cfg.validate())
+	cfg.BootstrapEtcdEndpoints = []string{"http://etcd-0:2379"}
+	s.Nil(cfg.validate())
+
+	s.Equal([]byte("/cadence/ringpop0"), etcdPrefixEnd("/cadence/ringpop/"))
+	s.Equal([]byte("b"), etcdPrefixEnd("a\xff"))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		s.Nil(json.NewDecoder(r.Body).Decode(&req))
+		switch r.URL.Path {
+		case "/v3/kv/range":
+			// base64 of /cadence/ringpop/ and of /cadence/ringpop0
+			s.Equal("L2NhZGVuY2UvcmluZ3BvcC8=", req["key"])
+			s.Equal("L2NhZGVuY2UvcmluZ3BvcDA=", req["range_end"])
+			// values are 10.0.0.1:7933 to 10.0.0.4:7933
+			w.Write([]byte(`{"kvs": [
+				{"key": "L2NhZGVuY2UvcmluZ3BvcC8x", "value": "MTAuMC4wLjE6NzkzMw==", "lease": "7"},
+				{"key": "L2NhZGVuY2UvcmluZ3BvcC8y", "value": "MTAuMC4wLjI6NzkzMw==", "lease": "8"},
+				{"key": "L2NhZGVuY2UvcmluZ3BvcC8z", "value": "MTAuMC4wLjM6NzkzMw=="},
+				{"key": "L2NhZGVuY2UvcmluZ3BvcC80", "value": "MTAuMC4wLjQ6NzkzMw==", "lease": 7}
+			]}`))
+		case "/v3/lease/timetolive":
+			if req["ID"] == float64(7) {
+				w.Write([]byte(`{"ID": "7", "TTL": "10"}`))
+			} else {
+				w.Write([]byte(`{"ID": "8", "TTL": "-1"}`))
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	provider := newEtcdProvider([]string{"http://127.0.0.1:1", server.URL}, "/cadence/ringpop/", false, time.Second)
+	hosts, err := provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933", "10.0.0.2:7933", "10.0.0.3:7933", "10.0.0.4:7933"}, hosts)
+
+	provider.requireLease = true
+	hosts, err = provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933", "10.0.0.4:7933"}, hosts)
+
+	provider.endpoints = []string{"http://127.0.0.1:1"}
+	_, err = provider.Hosts()
+	s.NotNil(err)
+
+	// the timeout bounds the whole call rather than each request
+	released := make(chan struct{})
+	defer close(released)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-released:
+		case <-time.After(200 * time.Millisecond):
+		}
+		server.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer slow.Close()
+	provider = newEtcdProvider([]string{slow.URL, slow.URL}, "/cadence/ringpop/", true, 300*time.Millisecond)
+	start := time.Now()
+	_, err = provider.Hosts()
+	s.NotNil(err)
+	s.True(time.Since(start) < time.Second)
+}
+
+func (s *RingpopDiscoverySuite) TestSRVProvider() {
+	cfg := &Ringpop{Name: "test", BootstrapMode: BootstrapModeDNSSRV, BootstrapHosts: []string{"cadence:7933"}}
+	s.NotNil(cfg.validate())
+	cfg.BootstrapHosts = []string{"_ringpop._tcp.cadence.example.com"}
+	s.Nil(cfg.validate())
+
+	records := []*net.SRV{
+		{Target: "c.example.com.", Port: 7933, Priority: 20, Weight: 10},
+		{Target: "b.example.com.", Port: 7933, Priority: 10, Weight: 0},
+		{Target: "a.example.com.", Port: 7933, Priority: 20, Weight: 90},
+		{Target: "d.example.com.", Port: 7933, Priority: 10, Weight: 50},
+	}
+	resolver := &fakeResolver{
+		srv: map[string][]*net.SRV{"_ringpop._tcp.cadence.example.com": records},
+		hosts: map[string][]string{
+			"a.example.com": {"10.0.0.a"},
+			"b.example.com": {"10.0.0.b"},
+			"c.example.com": {"10.0.0.c"},
+			"d.example.com": {"10.0.0.d"},
+		},
+	}
+	provider := newSRVProvider(cfg.BootstrapHosts, false, resolver)
+	hosts, err := provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.a:7933", "10.0.0.b:7933", "10.0.0.c:7933", "10.0.0.d:7933"}, hosts)
+
+	// the lowest pick selects the zero weight record first, the highest the heaviest
+	provider.honorPriority = true
+	provider.randomWeighted = func(n int) int { return 0 }
+	hosts, err = provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.b:7933", "10.0.0.d:7933", "10.0.0.c:7933", "10.0.0.a:7933"}, hosts)
+	provider.randomWeighted = func(n int) int { return n - 1 }
+	hosts, err = provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.d:7933", "10.0.0.b:7933", "10.0.0.a:7933", "10.0.0.c:7933"}, hosts)
+
+	// within a priority, records are selected first in proportion to their weight
+	random := rand.New(rand.NewSource(1))
+	first := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		ordered := orderSRVByPriority(records, random.Intn)
+		s.Equal(uint16(10), ordered[0].Priority)
+		s.Equal(uint16(10), ordered[1].Priority)
+		first[ordered[2].Target]++
+	}
+	s.InDelta(900, first["a.example.com."], 50)
+
+	resolver.srv = nil
+	_, err = provider.Hosts()
+	s.IsType(&DNSLookupError{}, err)
+	s.False(err.(*DNSLookupError).Temporary)
+}
+
+func (s *RingpopDiscoverySuite) TestDNSProviderResolver() {
+	resolver := &fakeResolver{hosts: map[string][]string{
+		"cadence-a.example.com": {"10.0.0.1", "10.0.0.2"},
+		"cadence-b.example.com": {"2001:db8::1"},
+	}}
+	cfg := &Ringpop{
+		Name:           "test",
+		BootstrapMode:  BootstrapModeDNS,
+		BootstrapHosts: []string{"cadence-a.example.com:7933", "cadence-b.example.com:7934"},
+		Resolver:       resolver,
+	}
+	provider, err := newSourceDiscoveryProvider(cfg)
+	s.Nil(err)
+	hosts, err := provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933", "10.0.0.2:7933", "[2001:db8::1]:7934"}, hosts)
+
+	// an unknown name is a permanent failure, a failing resolver a temporary one
+	cfg.BootstrapHosts = []string{"cadence-c.example.com:7933"}
+	provider, err = newSourceDiscoveryProvider(cfg)
+	s.Nil(err)
+	_, err = provider.Hosts()
+	s.IsType(&DNSLookupError{}, err)
+	s.False(err.(*DNSLookupError).Temporary)
+	s.Equal("cadence-c.example.com", err.(*DNSLookupError).Host)
+
+	resolver.err = &net.DNSError{Err: "server misbehaving", Name: "cadence-c.example.com", IsTemporary: true}
+	_, err = provider.Hosts()
+	s.IsType(&DNSLookupError{}, err)
+	s.True(err.(*DNSLookupError).Temporary)
+}
+
+func (s *RingpopDiscoverySuite) TestCompositeDiscoveryError() {
+	resolver := &fakeResolver{}
+	provider := newCompositeProvider(1, []namedProvider{
+		{name: "dns", provider: newDNSProvider([]string{"cadence-c.example.com:7933"}, resolver)},
+		{name: "file", provider: newFileProvider("/does/not/exist", "")},
+	})
+	_, err := provider.Hosts()
+	s.IsType(&CompositeDiscoveryError{}, err)
+	compositeErr := err.(*CompositeDiscoveryError)
+	s.Equal([]string{"dns", "file"}, compositeErr.Failed)
+	s.IsType(&DNSLookupError{}, compositeErr.Causes[0])
+	s.Contains(err.Error(), "0 of 2 sources succeeded, 1 required")
+	s.Contains(err.Error(), "cadence-c.example.com")
+	// the missing file may show up on a retry
+	s.True(isRetryableBootstrapError(err))
+
+	// an unknown name alone isn't worth retrying
+	provider.sources = provider.sources[:1]
+	_, err = provider.Hosts()
+	s.IsType(&CompositeDiscoveryError{}, err)
+	s.False(isRetryableBootstrapError(err))
+
+	resolver.err = &net.DNSError{Err: "server misbehaving", Name: "cadence-c.example.com", IsTemporary: true}
+	_, err = provider.Hosts()
+	s.True(isRetryableBootstrapError(err))
+}
+
+func (s *RingpopDiscoverySuite) TestDNSForceFreshOnRetry() {
+	cfg := &Ringpop{
+		Name:                 "test",
+		BootstrapMode:        BootstrapModeDNS,
+		BootstrapHosts:       []string{"cadence.example.com:7933"},
+		DNSForceFreshOnRetry: true,
+	}
+	f, err := NewFactory(cfg)
+	s.Nil(err)
+	s.Equal(cfg, f.sourceConfig())
+
+	f.setFreshDNS(true)
+	fresh := f.sourceConfig()
+	s.Equal(freshResolver, fresh.Resolver)
+	s.Nil(cfg.Resolver)
+	provider, err := newSourceDiscoveryProvider(fresh)
+	s.Nil(err)
+	s.Equal(freshResolver, provider.(*dnsProvider).resolver)
+
+	// a custom resolver is kept, the factory can't bypass its caching
+	cfg.Resolver = &fakeResolver{}
+	s.Equal(cfg.Resolver, f.sourceConfig().Resolver)
+	f.setFreshDNS(false)
+	s.Equal(cfg, f.sourceConfig())
+}
+
+// fakeResolver answers lookups from canned records, names without
+// records are reported as NXDOMAIN unless err is set
+type fakeResolver struct {
+	hosts map[string][]string
+	srv   map[string][]*net.SRV
+	err   error
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if addrs, ok := r.hosts[host]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: dnsNoSuchHost, Name: host}
+}
+
+func (r *fakeResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	if r.err != nil {
+		return "", nil, r.err
+	}
+	if records, ok := r.srv[name]; ok {
+		return name, append([]*net.SRV(nil), records...), nil
+	}
+	return "", nil, &net.DNSError{Err: dnsNoSuchHost, Name: name}
+}
+
+func (s *RingpopDiscoverySuite) TestMDNS() {
+	cfg := &Ringpop{Name: "test", BootstrapMode: BootstrapModeMDNS}
+	s.NotNil(cfg.validate())
+	cfg.BootstrapMDNSService = "cadence"
+	s.NotNil(cfg.validate())
+	cfg.BootstrapMDNSService = "_cadence-ringpop._tcp"
+	s.Nil(cfg.validate())
+	s.Equal("_cadence-ringpop._tcp.local.", mdnsServiceName(cfg.BootstrapMDNSService))
+	s.Equal("_cadence-ringpop._tcp.local.", mdnsServiceName("_cadence-ringpop._tcp.local."))
+
+	_, err := newMDNSResponder(cfg.BootstrapMDNSService, "[2001:db8::1]:7933")
+	s.NotNil(err)
+
+	// a query answered by two responders is collected into their host:port
+	name, err := dnsmessage.NewName(mdnsServiceName(cfg.BootstrapMDNSService))
+	s.Nil(err)
+	query, err := (&dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: 42},
+		Questions: []dnsmessage.Question{{Name: name, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET}},
+	}).Pack()
+	s.Nil(err)
+
+	collector := newMDNSCollector(mdnsServiceName(cfg.BootstrapMDNSService))
+	for _, address := range []string{"10.0.0.1:7933", "10.0.0.2:7934", "10.0.0.1:7933"} {
+		responder, err := newMDNSResponder(cfg.BootstrapMDNSService, address)
+		s.Nil(err)
+		resp, ok := responder.answer(query)
+		s.True(ok)
+		var msg dnsmessage.Message
+		s.Nil(msg.Unpack(resp))
+		s.Equal(uint16(42), msg.Header.ID)
+		collector.add(resp)
+	}
+	s.Equal([]string{"10.0.0.1:7933", "10.0.0.2:7934"}, collector.hosts())
+
+	// responders ignore other services and responses
+	responder, err := newMDNSResponder("_other._tcp", "10.0.0.1:7933")
+	s.Nil(err)
+	_, ok := responder.answer(query)
+	s.False(ok)
+	collector.add(query)
+	s.Len(collector.hosts(), 2)
+}
@@ -0,0 +1,221 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// etcdProvider is a discovery provider returning the values of the
+	// keys under a prefix, which nodes register their host:port under.
+	// It uses the json gateway of the etcd v3 api. When leases are
+	// required, only the keys attached to a live lease are returned.
+	// The timeout bounds the whole call, across endpoints and leases
+	etcdProvider struct {
+		endpoints    []string
+		prefix       string
+		requireLease bool
+		timeout      time.Duration
+		client       *http.Client
+	}
+
+	// etcdRangeResponse is the range response of the json gateway, which
+	// encodes bytes in base64 just like encoding/json
+	etcdRangeResponse struct {
+		Kvs []struct {
+			Key   []byte    `json:"key"`
+			Value []byte    `json:"value"`
+			Lease etcdInt64 `json:"lease"`
+		} `json:"kvs"`
+	}
+
+	etcdLeaseResponse struct {
+		TTL etcdInt64 `json:"TTL"`
+	}
+
+	// etcdInt64 is an int64 of the json gateway,
+	// which encodes them either as strings or numbers
+	etcdInt64 int64
+)
+
+// UnmarshalJSON accepts both the string and the number form
+func (i *etcdInt64) UnmarshalJSON(data []byte) error {
+	v, err := strconv.ParseInt(strings.Trim(string(data), `"`), 10, 64)
+	if err != nil {
+		return err
+	}
+	*i = etcdInt64(v)
+	return nil
+}
+
+func newEtcdProvider(endpoints []string, prefix string, requireLease bool, timeout time.Duration) *etcdProvider {
+	return &etcdProvider{
+		endpoints:    endpoints,
+		prefix:       prefix,
+		requireLease: requireLease,
+		timeout:      timeout,
+		client:       &http.Client{},
+	}
+}
+
+// Hosts implements discovery.DiscoverProvider, the endpoints
+// are tried in order until one of them answers
+func (p *etcdProvider) Hosts() ([]string, error) {
+	ctx := context.Background()
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+	var err error
+	for _, endpoint := range p.endpoints {
+		var hosts []string
+		if hosts, err = p.hostsFrom(ctx, endpoint); err == nil {
+			return hosts, nil
+		}
+	}
+	return nil, fmt.Errorf("ringpop etcd discovery of prefix %q: %v", p.prefix, err)
+}
+
+func (p *etcdProvider) hostsFrom(ctx context.Context, endpoint string) ([]string, error) {
+	var kvs etcdRangeResponse
+	err := p.post(ctx, endpoint, "/v3/kv/range", map[string][]byte{
+		"key":       []byte(p.prefix),
+		"range_end": etcdPrefixEnd(p.prefix),
+	}, &kvs)
+	if err != nil {
+		return nil, err
+	}
+
+	var live map[etcdInt64]bool
+	if p.requireLease {
+		if live, err = p.liveLeases(ctx, endpoint, kvs); err != nil {
+			return nil, err
+		}
+	}
+	var hosts []string
+	for _, kv := range kvs.Kvs {
+		if p.requireLease && !live[kv.Lease] {
+			continue
+		}
+		hosts = append(hosts, string(kv.Value))
+	}
+	return hosts, nil
+}
+
+// liveLeases looks the leases of the keys up concurrently, once per
+// lease since several keys may share one, and tells which are alive
+func (p *etcdProvider) liveLeases(ctx context.Context, endpoint string, kvs etcdRangeResponse) (map[etcdInt64]bool, error) {
+	var ids []etcdInt64
+	seen := make(map[etcdInt64]bool)
+	for _, kv := range kvs.Kvs {
+		if kv.Lease != 0 && !seen[kv.Lease] {
+			seen[kv.Lease] = true
+			ids = append(ids, kv.Lease)
+		}
+	}
+
+	live := make(map[etcdInt64]bool, len(ids))
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var lastErr error
+	for _, id := range ids {
+		wg.Add(1)
+		go func(id etcdInt64) {
+			defer wg.Done()
+			var lease etcdLeaseResponse
+			err := p.post(ctx, endpoint, "/v3/lease/timetolive", map[string]etcdInt64{"ID": id}, &lease)
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				lastErr = err
+				return
+			}
+			// the ttl of an expired lease is -1
+			live[id] = lease.TTL > 0
+		}(id)
+	}
+	wg.Wait()
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return live, nil
+}
+
+func (p *etcdProvider) post(ctx context.Context, endpoint string, path string, body interface{}, result interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(endpoint, "/")+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("POST %v%v returned %v", endpoint, path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// etcdPrefixEnd returns the end of the range of the keys with
+// the prefix, which is the prefix with its last byte incremented
+func etcdPrefixEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// the prefix is all 0xff, the range ends with the keyspace
+	return []byte{0}
+}
+
+// validateEtcdEndpoints checks that the endpoints are http or https urls
+func validateEtcdEndpoints(endpoints []string) error {
+	if len(endpoints) == 0 {
+		return fmt.Errorf("ringpop config missing bootstrap etcd endpoints param")
+	}
+	for _, endpoint := range endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			return fmt.Errorf("ringpop config bootstrap etcd endpoint %q must be an http or https url", endpoint)
+		}
+	}
+	return nil
+}
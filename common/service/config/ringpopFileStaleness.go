@@ -0,0 +1,74 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/uber-common/bark"
+)
+
+// fileStaleness tracks the size and modification time of the bootstrap
+// file across reads, to detect a file served stale by an nfs cache
+type fileStaleness struct {
+	mutex     sync.Mutex
+	modTime   time.Time
+	size      int64
+	changedAt time.Time
+}
+
+// observe records the file info read at now and returns true when
+// the file hasn't changed for longer than the staleness window
+func (s *fileStaleness) observe(info os.FileInfo, now time.Time, window time.Duration) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.changedAt.IsZero() || !info.ModTime().Equal(s.modTime) || info.Size() != s.size {
+		s.modTime, s.size, s.changedAt = info.ModTime(), info.Size(), now
+		return false
+	}
+	if now.Sub(s.changedAt) < window {
+		return false
+	}
+	// restart the window so that a stale file is re-read once per window
+	s.changedAt = now
+	return true
+}
+
+// checkFileStaleness is a seed list step that re-reads the bootstrap
+// file when it hasn't changed for longer than the staleness window
+func (factory *RingpopFactory) checkFileStaleness(hosts []string) ([]string, error) {
+	path := factory.config.BootstrapFile
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !factory.fileStaleness.observe(info, factory.clock.Now(), factory.config.BootstrapFileStaleness) {
+		return hosts, nil
+	}
+	factory.bootstrapLogger().WithFields(bark.Fields{
+		"file":    path,
+		"modTime": info.ModTime(),
+		"size":    info.Size(),
+	}).Warn("Ringpop bootstrap file unchanged for longer than the staleness window, re-reading it")
+	return newFileProvider(path, factory.config.BootstrapFileFormat).Hosts()
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/dgryski/go-farm"
+)
+
+// defaultReplicaPoints is the number of points of each member
+// on the ringpop hash ring when not set by HashRingReplicaPoints
+const defaultReplicaPoints = 100
+
+// DrainError is returned by WaitUntilDrained when the context
+// expires while this node still owns ranges of the hash ring
+type DrainError struct {
+	OwnedRanges int
+	Err         error
+}
+
+// Error implements error
+func (e *DrainError) Error() string {
+	return fmt.Sprintf("ringpop drain stalled with %v hash ring ranges still owned: %v", e.OwnedRanges, e.Err)
+}
+
+// ringToken is a point of a member on the hash ring, the member owns
+// the keys hashing after the previous token up to and including it
+type ringToken struct {
+	hash   uint32
+	member string
+}
+
+// PrepareLeave returns the hash ring ranges owned by this node, keyed by
+// the member that will own them once this node has left the ring, so that
+// the caller can hand them off before calling Destroy. A range is written
+// as "(start,end]" and holds the keys with a hash after start up to end,
+// it wraps around zero when start is greater than end. The ring is not
+// changed, the ranges of a ring without other members are not returned
+func (factory *RingpopFactory) PrepareLeave() (map[string][]string, error) {
+	_, rp := factory.instance()
+	if rp == nil {
+		return nil, ErrRingpopNotCreated
+	}
+	self, err := rp.WhoAmI()
+	if err != nil {
+		return nil, err
+	}
+	members, err := rp.GetReachableMembers()
+	if err != nil {
+		return nil, err
+	}
+	return handoffRanges(self, members, factory.replicaPoints()), nil
+}
+
+// WaitUntilDrained blocks until this node owns no range of the hash ring,
+// which happens once it has left the ring, see Leave. When the context
+// expires first, the returned error is a *DrainError with the number of
+// ranges this node still owns
+func (factory *RingpopFactory) WaitUntilDrained(ctx context.Context) error {
+	_, rp := factory.instance()
+	if rp == nil {
+		return ErrRingpopNotCreated
+	}
+	self, err := rp.WhoAmI()
+	if err != nil {
+		return err
+	}
+	return factory.waitUntilDrained(ctx, self, func() ([]string, error) {
+		return rp.GetReachableMembers()
+	})
+}
+
+func (factory *RingpopFactory) waitUntilDrained(ctx context.Context, self string, getMembers func() ([]string, error)) error {
+	owned := -1
+	err := factory.waitForMembership(ctx, getMembers, func(members []string) bool {
+		owned = ownedRanges(self, members, factory.replicaPoints())
+		return owned == 0
+	})
+	if err != nil {
+		return &DrainError{OwnedRanges: owned, Err: err}
+	}
+	return nil
+}
+
+// ownedRanges returns the number of hash ring ranges owned by self
+func ownedRanges(self string, members []string, replicaPoints int) int {
+	owned := 0
+	for _, token := range ringTokens(members, replicaPoints) {
+		if token.member == self {
+			owned++
+		}
+	}
+	return owned
+}
+
+func (factory *RingpopFactory) replicaPoints() int {
+	if points := factory.config.HashRingReplicaPoints; points > 0 {
+		return points
+	}
+	return defaultReplicaPoints
+}
+
+// handoffRanges computes the ranges of self and their next owner from the
+// tokens of the members, placed on the ring the same way ringpop places them
+func handoffRanges(self string, members []string, replicaPoints int) map[string][]string {
+	tokens := ringTokens(append([]string{self}, members...), replicaPoints)
+	handoff := make(map[string][]string)
+	for i, token := range tokens {
+		if token.member != self {
+			continue
+		}
+		next := ""
+		for j := 1; j < len(tokens); j++ {
+			if member := tokens[(i+j)%len(tokens)].member; member != self {
+				next = member
+				break
+			}
+		}
+		if len(next) == 0 {
+			// no other member to hand off to
+			return handoff
+		}
+		prev := tokens[(i+len(tokens)-1)%len(tokens)].hash
+		handoff[next] = append(handoff[next], fmt.Sprintf("(%v,%v]", prev, token.hash))
+	}
+	return handoff
+}
+
+// ringTokens returns the sorted tokens of the members, ties are
+// broken by member so that every node computes the same ring
+func ringTokens(members []string, replicaPoints int) []ringToken {
+	seen := make(map[string]struct{}, len(members))
+	var tokens []ringToken
+	for _, member := range members {
+		if _, ok := seen[member]; ok {
+			continue
+		}
+		seen[member] = struct{}{}
+		for i := 0; i < replicaPoints; i++ {
+			hash := farm.Fingerprint32([]byte(fmt.Sprintf("%s%v", member, i)))
+			tokens = append(tokens, ringToken{hash: hash, member: member})
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		if tokens[i].hash != tokens[j].hash {
+			return tokens[i].hash < tokens[j].hash
+		}
+		return tokens[i].member < tokens[j].member
+	})
+	return tokens
+}
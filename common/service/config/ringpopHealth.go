@@ -0,0 +1,466 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/uber-common/bark"
+	"github.com/uber/cadence/common/logging"
+	"github.com/uber/ringpop-go"
+	"github.com/uber/ringpop-go/swim"
+	tcg "github.com/uber/tchannel-go"
+)
+
+const (
+	selfOnlyBootstrapPolicyWarn = "warn"
+	selfOnlyBootstrapPolicyFail = "fail"
+
+	maxMembersPolicyWarn  = "warn"
+	maxMembersPolicyLeave = "leave"
+)
+
+var (
+	// ErrRingpopNotCreated is returned when the factory hasn't created a ringpop instance yet
+	ErrRingpopNotCreated = errors.New("ringpop has not been created by this factory")
+	// ErrRingpopChannelNotListening is returned when the tchannel used by ringpop is not listening
+	ErrRingpopChannelNotListening = errors.New("ringpop tchannel is not listening")
+	// ErrRingpopNotBootstrapped is returned when the ringpop instance is not bootstrapped
+	ErrRingpopNotBootstrapped = errors.New("ringpop is not bootstrapped")
+	// ErrRingpopSelfOnly is returned when this node is the only member of the ring
+	// after bootstrapping while it isn't expected to be the first node
+	ErrRingpopSelfOnly = errors.New("ringpop bootstrapped with itself as the only member")
+	// ErrRingpopPingFailed is returned by SelfTest when none of the
+	// other members of the ring answers the swim ping of this node
+	ErrRingpopPingFailed = errors.New("ringpop failed to ping any other member of the ring")
+)
+
+// StartupBarrierError is returned by StartupBarrier when the
+// context expires before all the expected peers are members
+type StartupBarrierError struct {
+	Missing []string
+	Err     error
+}
+
+// Error implements error
+func (e *StartupBarrierError) Error() string {
+	return fmt.Sprintf("ringpop startup barrier expected peers %v are missing: %v", e.Missing, e.Err)
+}
+
+// MaxMembersError is returned when the ring has more members than the
+// max members, which is almost always the sign of two rings cross
+// joining. Once this node left the ring per the leave policy, Ready
+// and Healthy keep returning it
+type MaxMembersError struct {
+	Count      int
+	MaxMembers int
+}
+
+// Error implements error
+func (e *MaxMembersError) Error() string {
+	return fmt.Sprintf("ringpop has %v members, more than the %v max members, rings may have cross joined", e.Count, e.MaxMembers)
+}
+
+// SelfTest verifies that ringpop is functional end to end: the
+// tchannel is listening, ringpop is bootstrapped and at least one
+// other member of the ring answers the swim ping of this node before
+// the context deadline. Being the only member of the ring fails with
+// ErrRingpopSelfOnly, unless this node is configured as the first node
+func (factory *RingpopFactory) SelfTest(ctx context.Context) error {
+	ch, rp := factory.instance()
+	if rp == nil {
+		return ErrRingpopNotCreated
+	}
+	if ch.State() != tcg.ChannelListening {
+		return ErrRingpopChannelNotListening
+	}
+	if !rp.Ready() {
+		return ErrRingpopNotBootstrapped
+	}
+
+	self, err := rp.WhoAmI()
+	if err != nil {
+		return fmt.Errorf("ringpop self test failed to get its address: %v", err)
+	}
+	members, err := rp.GetReachableMembers()
+	if err != nil {
+		return fmt.Errorf("ringpop self test failed to list members: %v", err)
+	}
+	return factory.pingPeers(ctx, factory.swimCaller(ch), self, members)
+}
+
+// pingPeers sends the swim ping of this node to the members other
+// than self, one at a time until one of them answers
+func (factory *RingpopFactory) pingPeers(ctx context.Context, call swimCaller, self string, members []string) error {
+	var peers []string
+	for _, member := range members {
+		if membershipKey(member) != membershipKey(self) {
+			peers = append(peers, member)
+		}
+	}
+	if len(peers) == 0 {
+		if factory.config.FirstNode {
+			return nil
+		}
+		return ErrRingpopSelfOnly
+	}
+	ping, _, err := factory.selfPing(ctx, call, self)
+	if err != nil {
+		return fmt.Errorf("ringpop self test failed: %v", err)
+	}
+
+	var lastErr error
+	for _, peer := range peers {
+		if lastErr = ctx.Err(); lastErr != nil {
+			break
+		}
+		var resp swimPing
+		if lastErr = call(ctx, peer, swimPingEndpoint, ping, &resp); lastErr == nil {
+			return nil
+		}
+	}
+	factory.logger.WithFields(bark.Fields{
+		logging.TagErr: lastErr,
+		"peers":        len(peers),
+	}).Warn("Ringpop self test failed to ping any other member")
+	return ErrRingpopPingFailed
+}
+
+// Ready returns nil when ringpop is bootstrapped and all of the
+// configured required members are part of the ring, otherwise it
+// returns an error describing why this node is not ready.
+// Once the node has been ready, missing required members only make it
+// not ready after they have been missing for longer than the readiness
+// debounce, so that routine gossip churn doesn't flap the readiness
+func (factory *RingpopFactory) Ready() error {
+	_, rp := factory.instance()
+	if rp == nil {
+		return ErrRingpopNotCreated
+	}
+	if err := factory.leftMaxMembers(); err != nil {
+		return err
+	}
+	if !rp.Ready() {
+		return ErrRingpopNotBootstrapped
+	}
+	missing, err := factory.missingRequiredMembers(rp)
+	if err != nil {
+		return err
+	}
+	var membersErr error
+	if len(missing) > 0 {
+		membersErr = missingRequiredMembersError(missing)
+	}
+	return factory.debounceReadiness(membersErr)
+}
+
+// Healthy returns nil when ringpop is bootstrapped and all of the
+// configured required members are part of the ring. Unlike Ready,
+// missing required members are reported right away, without waiting
+// for the readiness debounce
+func (factory *RingpopFactory) Healthy() error {
+	_, rp := factory.instance()
+	if rp == nil {
+		return ErrRingpopNotCreated
+	}
+	if err := factory.leftMaxMembers(); err != nil {
+		return err
+	}
+	if !rp.Ready() {
+		return ErrRingpopNotBootstrapped
+	}
+	missing, err := factory.missingRequiredMembers(rp)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return missingRequiredMembersError(missing)
+	}
+	return nil
+}
+
+// missingRequiredMembers returns the required members which
+// are not reachable members of the ring
+func (factory *RingpopFactory) missingRequiredMembers(rp *ringpop.Ringpop) ([]string, error) {
+	if len(factory.config.RequiredMembers) == 0 {
+		return nil, nil
+	}
+	members, err := rp.GetReachableMembers()
+	if err != nil {
+		return nil, err
+	}
+	return missingMembers(members, factory.config.RequiredMembers), nil
+}
+
+func missingRequiredMembersError(missing []string) error {
+	return fmt.Errorf("ringpop is missing required members: %v", strings.Join(missing, ", "))
+}
+
+// debounceReadiness suppresses the given membership error of a ready
+// node until it has been reported for longer than the readiness
+// debounce. A node that isn't ready yet, or no longer, gets the error
+// right away, and a nil error makes it ready again immediately
+func (factory *RingpopFactory) debounceReadiness(err error) error {
+	factory.mutex.Lock()
+	defer factory.mutex.Unlock()
+
+	if err == nil {
+		factory.wasReady = true
+		factory.unreadySince = time.Time{}
+		return nil
+	}
+	if !factory.wasReady {
+		return err
+	}
+	now := factory.clock.Now()
+	if factory.unreadySince.IsZero() {
+		factory.unreadySince = now
+	}
+	if now.Sub(factory.unreadySince) < factory.config.ReadinessDebounce {
+		return nil
+	}
+	factory.wasReady = false
+	factory.unreadySince = time.Time{}
+	return err
+}
+
+// WaitForMembership blocks until the members of the ring satisfy
+// the predicate, polling them at the membership poll interval,
+// and returns the context error if the context expires first
+func (factory *RingpopFactory) WaitForMembership(ctx context.Context, pred func(members []string) bool) error {
+	_, rp := factory.instance()
+	if rp == nil {
+		return ErrRingpopNotCreated
+	}
+	return factory.waitForMembership(ctx, func() ([]string, error) {
+		return rp.GetReachableMembers()
+	}, pred)
+}
+
+// StartupBarrier blocks until all the expected addresses are members of
+// the ring. When the context expires first, the returned error is a
+// *StartupBarrierError listing the expected peers still missing
+func (factory *RingpopFactory) StartupBarrier(ctx context.Context, expected []string) error {
+	_, rp := factory.instance()
+	if rp == nil {
+		return ErrRingpopNotCreated
+	}
+	return factory.startupBarrier(ctx, func() ([]string, error) {
+		return rp.GetReachableMembers()
+	}, expected)
+}
+
+func (factory *RingpopFactory) startupBarrier(ctx context.Context, getMembers func() ([]string, error), expected []string) error {
+	missing := expected
+	err := factory.waitForMembership(ctx, getMembers, func(members []string) bool {
+		missing = missingMembers(members, expected)
+		return len(missing) == 0
+	})
+	if err != nil {
+		return &StartupBarrierError{Missing: missing, Err: err}
+	}
+	return nil
+}
+
+func (factory *RingpopFactory) waitForMembership(
+	ctx context.Context,
+	getMembers func() ([]string, error),
+	pred func(members []string) bool,
+) error {
+	for {
+		members, err := getMembers()
+		if err == nil && pred(members) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-factory.clock.After(factory.config.MembershipPollInterval):
+		}
+	}
+}
+
+// checkSelfOnly applies the self only bootstrap policy when this
+// node is the only member of the ring, unless it's the first node
+func (factory *RingpopFactory) checkSelfOnly(rp *ringpop.Ringpop) error {
+	if factory.config.FirstNode {
+		return nil
+	}
+	self, err := rp.WhoAmI()
+	if err != nil {
+		return err
+	}
+	members, err := rp.GetReachableMembers()
+	if err != nil {
+		return err
+	}
+	if !isSelfOnly(members, self) {
+		return nil
+	}
+	if strings.ToLower(factory.config.SelfOnlyBootstrapPolicy) == selfOnlyBootstrapPolicyFail {
+		return ErrRingpopSelfOnly
+	}
+	factory.bootstrapLogger().WithField("self", self).Warn("Ringpop bootstrapped with itself as the only member, the cluster may be partitioned")
+	return nil
+}
+
+// checkMaxMembers applies the max members policy when the ring has
+// more members than allowed. With the leave policy, this node leaves
+// the ring and the returned error is a *MaxMembersError
+func (factory *RingpopFactory) checkMaxMembers(rp *ringpop.Ringpop) error {
+	return factory.applyMaxMembersPolicy(func() (int, error) {
+		return rp.CountReachableMembers()
+	}, rp.SelfEvict)
+}
+
+func (factory *RingpopFactory) applyMaxMembersPolicy(countMembers func() (int, error), leave func() error) error {
+	maxMembers := factory.config.MaxMembers
+	if maxMembers <= 0 {
+		return nil
+	}
+	count, err := countMembers()
+	if err != nil || count <= maxMembers {
+		return err
+	}
+
+	err = &MaxMembersError{Count: count, MaxMembers: maxMembers}
+	if strings.ToLower(factory.config.MaxMembersPolicy) != maxMembersPolicyLeave {
+		factory.logger.WithFields(bark.Fields{logging.TagErr: err}).Warn("Ringpop exceeds max members")
+		return nil
+	}
+	factory.logger.WithFields(bark.Fields{logging.TagErr: err}).Error("Ringpop exceeds max members, leaving the ring")
+	if evictErr := leave(); evictErr != nil {
+		factory.logger.WithFields(bark.Fields{logging.TagErr: evictErr}).Error("Ringpop failed to leave the ring")
+	}
+	return err
+}
+
+// startMaxMembersMonitor starts checking the member count against
+// the max members at every check interval, if max members is set
+func (factory *RingpopFactory) startMaxMembersMonitor(rp *ringpop.Ringpop) {
+	if factory.config.MaxMembers <= 0 {
+		return
+	}
+	factory.shutdownWG.Add(1)
+	go factory.maxMembersLoop(func() (int, error) {
+		return rp.CountReachableMembers()
+	}, rp.SelfEvict)
+}
+
+func (factory *RingpopFactory) maxMembersLoop(countMembers func() (int, error), leave func() error) {
+	defer factory.shutdownWG.Done()
+	for {
+		select {
+		case <-factory.shutdownCh:
+			return
+		case <-factory.clock.After(factory.config.MaxMembersCheckInterval):
+		}
+		err := factory.applyMaxMembersPolicy(countMembers, leave)
+		if maxErr, ok := err.(*MaxMembersError); ok {
+			// this node left the ring, which Ready reports from now on
+			factory.mutex.Lock()
+			factory.maxMembersErr = maxErr
+			factory.mutex.Unlock()
+			return
+		}
+		if err != nil {
+			factory.logger.WithFields(bark.Fields{logging.TagErr: err}).Warn("Ringpop failed to count members against max members")
+		}
+	}
+}
+
+// leftMaxMembers returns the *MaxMembersError of this node
+// once it left the ring per the max members leave policy
+func (factory *RingpopFactory) leftMaxMembers() error {
+	factory.mutex.Lock()
+	defer factory.mutex.Unlock()
+	return factory.maxMembersErr
+}
+
+// IsMember returns true when addr is a reachable member of the ring. The
+// addresses are compared once normalized, so that an ipv4-mapped ipv6
+// address or a host with a trailing dot still matches the member
+func (factory *RingpopFactory) IsMember(addr string) (bool, error) {
+	_, rp := factory.instance()
+	if rp == nil {
+		return false, ErrRingpopNotCreated
+	}
+	count, err := rp.CountReachableMembers(memberWithAddress(addr))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// memberWithAddress returns a predicate matching the member with
+// the given address, without building the list of all the members
+func memberWithAddress(addr string) swim.MemberPredicate {
+	target := normalizeMemberAddress(addr)
+	return func(member swim.Member) bool {
+		return member.Address == target || normalizeMemberAddress(member.Address) == target
+	}
+}
+
+// normalizeMemberAddress returns the canonical form of a member address
+func normalizeMemberAddress(addr string) string {
+	if host, port, err := net.SplitHostPort(addr); err == nil {
+		addr = net.JoinHostPort(strings.ToLower(strings.TrimSuffix(host, ".")), port)
+	}
+	return normalizeHost(addr, addressNormalizationIPv4)
+}
+
+// instance returns the channel and ringpop
+// instance created by this factory, if any
+func (factory *RingpopFactory) instance() (*tcg.Channel, *ringpop.Ringpop) {
+	factory.mutex.Lock()
+	defer factory.mutex.Unlock()
+	return factory.channel, factory.ringpop
+}
+
+// isSelfOnly returns true when self is the only member
+func isSelfOnly(members []string, self string) bool {
+	for _, member := range members {
+		if member != self {
+			return false
+		}
+	}
+	return true
+}
+
+// missingMembers returns the required hosts that are not in members
+func missingMembers(members []string, required []string) []string {
+	present := make(map[string]struct{}, len(members))
+	for _, member := range members {
+		present[normalizeHost(member, addressNormalizationIPv4)] = struct{}{}
+	}
+	var missing []string
+	for _, host := range required {
+		if _, ok := present[normalizeHost(host, addressNormalizationIPv4)]; !ok {
+			missing = append(missing, host)
+		}
+	}
+	return missing
+}
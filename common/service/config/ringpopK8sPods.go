@@ -0,0 +1,155 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// k8sServiceAccountDir holds the credentials of the pod service account
+	k8sServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	// defaultK8sAPIServer is the in-cluster api server address used
+	// when the KUBERNETES_SERVICE_HOST environment variable isn't set
+	defaultK8sAPIServer = "https://kubernetes.default.svc"
+
+	k8sPodPhaseRunning = "Running"
+	k8sPodReady        = "Ready"
+)
+
+type (
+	// k8sPodsProvider is a discovery provider returning the ip of the
+	// running and ready pods matching a label selector, along with the
+	// ringpop port. It uses the in-cluster service account credentials
+	k8sPodsProvider struct {
+		apiServer string
+		namespace string
+		selector  string
+		port      int
+		tokenFile string
+		client    *http.Client
+	}
+
+	k8sPodList struct {
+		Items []k8sPod `json:"items"`
+	}
+
+	k8sPod struct {
+		Metadata struct {
+			Name              string  `json:"name"`
+			DeletionTimestamp *string `json:"deletionTimestamp"`
+		} `json:"metadata"`
+		Status struct {
+			Phase      string `json:"phase"`
+			PodIP      string `json:"podIP"`
+			Conditions []struct {
+				Type   string `json:"type"`
+				Status string `json:"status"`
+			} `json:"conditions"`
+		} `json:"status"`
+	}
+)
+
+func newK8sPodsProvider(namespace string, selector string, port int, timeout time.Duration) *k8sPodsProvider {
+	apiServer := defaultK8sAPIServer
+	if host := os.Getenv("KUBERNETES_SERVICE_HOST"); len(host) > 0 {
+		apiServer = "https://" + net.JoinHostPort(host, os.Getenv("KUBERNETES_SERVICE_PORT"))
+	}
+	client := &http.Client{Timeout: timeout}
+	if ca, err := ioutil.ReadFile(k8sServiceAccountDir + "/ca.crt"); err == nil {
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(ca)
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	}
+	return &k8sPodsProvider{
+		apiServer: apiServer,
+		namespace: namespace,
+		selector:  selector,
+		port:      port,
+		tokenFile: k8sServiceAccountDir + "/token",
+		client:    client,
+	}
+}
+
+// Hosts implements discovery.DiscoverProvider
+func (p *k8sPodsProvider) Hosts() ([]string, error) {
+	pods, err := p.listPods()
+	if err != nil {
+		return nil, fmt.Errorf("ringpop k8s pod discovery for selector %q in namespace %v: %v", p.selector, p.namespace, err)
+	}
+	var hosts []string
+	for _, pod := range pods.Items {
+		if pod.usable() {
+			hosts = append(hosts, net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(p.port)))
+		}
+	}
+	return hosts, nil
+}
+
+func (p *k8sPodsProvider) listPods() (*k8sPodList, error) {
+	path := "/api/v1/namespaces/" + url.PathEscape(p.namespace) + "/pods?labelSelector=" + url.QueryEscape(p.selector)
+	req, err := http.NewRequest(http.MethodGet, p.apiServer+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	// the token is read on every call as the kubelet rotates it
+	if token, err := ioutil.ReadFile(p.tokenFile); err == nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %v returned %v", path, resp.Status)
+	}
+	var pods k8sPodList
+	if err := json.NewDecoder(resp.Body).Decode(&pods); err != nil {
+		return nil, err
+	}
+	return &pods, nil
+}
+
+// usable returns true for the running and ready pods
+// which have an ip and aren't terminating
+func (pod k8sPod) usable() bool {
+	if pod.Status.Phase != k8sPodPhaseRunning || len(pod.Status.PodIP) == 0 || pod.Metadata.DeletionTimestamp != nil {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == k8sPodReady {
+			return condition.Status == "True"
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"fmt"
+)
+
+// Lookup returns the address of the node owning key on the hash ring
+func (factory *RingpopFactory) Lookup(key string) (string, error) {
+	_, rp := factory.instance()
+	if rp == nil {
+		return "", ErrRingpopNotCreated
+	}
+	return rp.Lookup(key)
+}
+
+// LookupN returns the addresses of the n nodes owning key on the hash ring,
+// fewer when the ring has less than n members
+func (factory *RingpopFactory) LookupN(key string, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("ringpop lookup of %v nodes, n must be positive", n)
+	}
+	_, rp := factory.instance()
+	if rp == nil {
+		return nil, ErrRingpopNotCreated
+	}
+	return rp.LookupN(key, n)
+}
@@ -0,0 +1,362 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uber-common/bark"
+	"github.com/uber/cadence/common/logging"
+	tcg "github.com/uber/tchannel-go"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// mdnsBrowseWindow is how long responses are collected after a query
+	mdnsBrowseWindow = 2 * time.Second
+	// mdnsTTL is the ttl of the records advertised, in seconds
+	mdnsTTL = 120
+	// mdnsMaxMessageSize is the largest multicast dns message
+	mdnsMaxMessageSize = 9000
+)
+
+var (
+	// mdnsGroup is the ipv4 multicast dns group
+	mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+	// validMDNSService is a service type like _cadence-ringpop._tcp
+	validMDNSService = regexp.MustCompile(`^_[a-zA-Z0-9-]+\._(tcp|udp)(\.local)?\.?$`)
+)
+
+type (
+	// mdnsProvider is a discovery provider browsing the local network
+	// with multicast dns for the instances of a service type. Queries are
+	// sent from an ephemeral port, so the instances answer by unicast
+	mdnsProvider struct {
+		service string
+		window  time.Duration
+	}
+
+	// mdnsResponder answers the multicast dns queries
+	// for a service type with the address of this node
+	mdnsResponder struct {
+		conn     *net.UDPConn
+		service  dnsmessage.Name
+		instance dnsmessage.Name
+		target   dnsmessage.Name
+		ip       [4]byte
+		port     uint16
+	}
+
+	// mdnsCollector gathers the instances of a service from
+	// the records of the multicast dns responses
+	mdnsCollector struct {
+		service   string
+		instances []string
+		srvs      map[string]dnsmessage.SRVResource
+		ips       map[string]net.IP
+	}
+)
+
+// mdnsServiceName returns the fully qualified name of a service type
+func mdnsServiceName(service string) string {
+	service = strings.TrimSuffix(service, ".")
+	if !strings.HasSuffix(service, ".local") {
+		service += ".local"
+	}
+	return service + "."
+}
+
+func validateMDNSService(service string) error {
+	if len(service) == 0 {
+		return fmt.Errorf("ringpop config missing bootstrap mdns service param")
+	}
+	if !validMDNSService.MatchString(service) {
+		return fmt.Errorf("ringpop config bootstrap mdns service %q must be a service type like _cadence-ringpop._tcp", service)
+	}
+	return nil
+}
+
+func newMDNSProvider(service string, timeout time.Duration) *mdnsProvider {
+	window := mdnsBrowseWindow
+	if timeout > 0 && timeout < window {
+		window = timeout
+	}
+	return &mdnsProvider{
+		service: mdnsServiceName(service),
+		window:  window,
+	}
+}
+
+// Hosts implements discovery.DiscoverProvider
+func (p *mdnsProvider) Hosts() ([]string, error) {
+	hosts, err := p.browse()
+	if err != nil {
+		return nil, fmt.Errorf("ringpop mdns discovery for %v: %v", p.service, err)
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("ringpop mdns discovery found no instance of %v within %v", p.service, p.window)
+	}
+	return hosts, nil
+}
+
+func (p *mdnsProvider) browse() ([]string, error) {
+	name, err := dnsmessage.NewName(p.service)
+	if err != nil {
+		return nil, err
+	}
+	query := dnsmessage.Message{
+		Questions: []dnsmessage.Question{{Name: name, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.WriteToUDP(packed, mdnsGroup); err != nil {
+		return nil, err
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(p.window)); err != nil {
+		return nil, err
+	}
+
+	collector := newMDNSCollector(p.service)
+	buf := make([]byte, mdnsMaxMessageSize)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				return collector.hosts(), nil
+			}
+			return nil, err
+		}
+		// malformed responses of other hosts are ignored
+		collector.add(buf[:n])
+	}
+}
+
+func newMDNSCollector(service string) *mdnsCollector {
+	return &mdnsCollector{
+		service: service,
+		srvs:    make(map[string]dnsmessage.SRVResource),
+		ips:     make(map[string]net.IP),
+	}
+}
+
+// add records the instances and addresses found in a response
+func (c *mdnsCollector) add(data []byte) {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(data); err != nil || !msg.Header.Response {
+		return
+	}
+	for _, r := range append(msg.Answers, msg.Additionals...) {
+		name := strings.ToLower(r.Header.Name.String())
+		switch body := r.Body.(type) {
+		case *dnsmessage.PTRResource:
+			if name == strings.ToLower(c.service) {
+				c.instances = append(c.instances, strings.ToLower(body.PTR.String()))
+			}
+		case *dnsmessage.SRVResource:
+			c.srvs[name] = *body
+		case *dnsmessage.AResource:
+			c.ips[name] = net.IP(body.A[:])
+		}
+	}
+}
+
+// hosts returns the host:port of the instances found
+func (c *mdnsCollector) hosts() []string {
+	var hosts []string
+	for _, instance := range c.instances {
+		srv, ok := c.srvs[instance]
+		if !ok {
+			continue
+		}
+		target := strings.ToLower(srv.Target.String())
+		host := strings.TrimSuffix(target, ".")
+		if ip, ok := c.ips[target]; ok {
+			host = ip.String()
+		}
+		hosts = append(hosts, net.JoinHostPort(host, strconv.Itoa(int(srv.Port))))
+	}
+	return dedupHosts(hosts)
+}
+
+// newMDNSResponder creates a responder advertising the given ipv4 address
+// for the service type, conn is nil until the responder listens
+func newMDNSResponder(service string, address string) (*mdnsResponder, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(host).To4()
+	if ip == nil {
+		return nil, fmt.Errorf("ringpop mdns can only advertise an ipv4 address, not %v", address)
+	}
+	portNum, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	label := strings.NewReplacer(".", "-", ":", "-").Replace(address)
+	serviceName, err := dnsmessage.NewName(mdnsServiceName(service))
+	if err != nil {
+		return nil, err
+	}
+	instance, err := dnsmessage.NewName(label + "." + serviceName.String())
+	if err != nil {
+		return nil, err
+	}
+	target, err := dnsmessage.NewName(label + ".local.")
+	if err != nil {
+		return nil, err
+	}
+	r := &mdnsResponder{
+		service:  serviceName,
+		instance: instance,
+		target:   target,
+		port:     uint16(portNum),
+	}
+	copy(r.ip[:], ip)
+	return r, nil
+}
+
+// answer returns the response to a query for the service type
+func (r *mdnsResponder) answer(query []byte) ([]byte, bool) {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(query); err != nil || msg.Header.Response {
+		return nil, false
+	}
+	asked := false
+	for _, q := range msg.Questions {
+		if strings.EqualFold(q.Name.String(), r.service.String()) && (q.Type == dnsmessage.TypePTR || q.Type == dnsmessage.TypeALL) {
+			asked = true
+		}
+	}
+	if !asked {
+		return nil, false
+	}
+
+	header := func(name dnsmessage.Name, typ dnsmessage.Type) dnsmessage.ResourceHeader {
+		return dnsmessage.ResourceHeader{Name: name, Type: typ, Class: dnsmessage.ClassINET, TTL: mdnsTTL}
+	}
+	resp := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: msg.Header.ID, Response: true, Authoritative: true},
+		// legacy unicast responses repeat the question
+		Questions: msg.Questions,
+		Answers: []dnsmessage.Resource{{
+			Header: header(r.service, dnsmessage.TypePTR),
+			Body:   &dnsmessage.PTRResource{PTR: r.instance},
+		}},
+		Additionals: []dnsmessage.Resource{
+			{
+				Header: header(r.instance, dnsmessage.TypeSRV),
+				Body:   &dnsmessage.SRVResource{Port: r.port, Target: r.target},
+			},
+			{
+				Header: header(r.target, dnsmessage.TypeA),
+				Body:   &dnsmessage.AResource{A: r.ip},
+			},
+		},
+	}
+	packed, err := resp.Pack()
+	if err != nil {
+		return nil, false
+	}
+	return packed, true
+}
+
+// serve answers the queries until the shutdown channel is closed
+func (r *mdnsResponder) serve(shutdownC <-chan struct{}, logger bark.Logger) {
+	go func() {
+		<-shutdownC
+		r.conn.Close()
+	}()
+	buf := make([]byte, mdnsMaxMessageSize)
+	for {
+		n, src, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-shutdownC:
+			default:
+				logger.WithFields(bark.Fields{logging.TagErr: err}).Warn("Ringpop mdns responder stopped")
+			}
+			return
+		}
+		resp, ok := r.answer(buf[:n])
+		if !ok {
+			continue
+		}
+		dst := src
+		if src.Port == mdnsGroup.Port {
+			dst = mdnsGroup
+		}
+		if _, err := r.conn.WriteToUDP(resp, dst); err != nil {
+			logger.WithFields(bark.Fields{logging.TagErr: err}).Warn("Ringpop mdns responder failed to answer")
+		}
+	}
+}
+
+// advertiseMDNS starts advertising the address of the channel
+func (factory *RingpopFactory) advertiseMDNS(ch *tcg.Channel) error {
+	address, err := factory.advertisedAddress(ch)
+	if err != nil {
+		return err
+	}
+	if len(address) == 0 {
+		address = ch.PeerInfo().HostPort
+	}
+	return factory.startMDNSResponder(address)
+}
+
+// startMDNSResponder advertises this node for the mdns bootstrap
+// mode, until the factory is stopped. It is only started once
+func (factory *RingpopFactory) startMDNSResponder(address string) error {
+	factory.mutex.Lock()
+	defer factory.mutex.Unlock()
+	if factory.mdnsResponder != nil {
+		return nil
+	}
+	r, err := newMDNSResponder(factory.config.BootstrapMDNSService, address)
+	if err != nil {
+		return err
+	}
+	if r.conn, err = net.ListenMulticastUDP("udp4", nil, mdnsGroup); err != nil {
+		return fmt.Errorf("ringpop mdns responder unable to listen: %v", err)
+	}
+	factory.mdnsResponder = r
+	factory.shutdownWG.Add(1)
+	go func() {
+		defer factory.shutdownWG.Done()
+		r.serve(factory.shutdownCh, factory.logger)
+	}()
+	return nil
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/uber/ringpop-go"
+	"github.com/uber/ringpop-go/swim"
+)
+
+const (
+	// MaintenanceLabel is the ringpop label set on a node under maintenance.
+	// Such a node stays in the ring to finish its work in flight, but routers
+	// shouldn't send it new work
+	MaintenanceLabel = "maintenance"
+
+	maintenanceLabelValue = "true"
+)
+
+type (
+	// MembersOption configures the members returned by RingpopFactory.Members
+	MembersOption func(*membersOptions)
+
+	membersOptions struct {
+		predicates []swim.MemberPredicate
+	}
+)
+
+// ExcludeMaintenance leaves out the members under maintenance
+func ExcludeMaintenance() MembersOption {
+	return func(opts *membersOptions) {
+		opts.predicates = append(opts.predicates, func(member swim.Member) bool {
+			return member.Labels[MaintenanceLabel] != maintenanceLabelValue
+		})
+	}
+}
+
+// Members returns the reachable members of the ring
+func (factory *RingpopFactory) Members(opts ...MembersOption) ([]string, error) {
+	_, rp := factory.instance()
+	if rp == nil {
+		return nil, ErrRingpopNotCreated
+	}
+	var options membersOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return rp.GetReachableMembers(options.predicates...)
+}
+
+// MembersMatching returns the reachable members of the ring whose labels
+// match the predicate, sorted. The predicate is given a copy of the labels
+func (factory *RingpopFactory) MembersMatching(pred func(labels map[string]string) bool) ([]string, error) {
+	_, rp := factory.instance()
+	if rp == nil {
+		return nil, ErrRingpopNotCreated
+	}
+	members, err := rp.GetReachableMembers(labelPredicate(pred))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+// labelPredicate adapts a predicate on labels to a swim member predicate
+func labelPredicate(pred func(labels map[string]string) bool) swim.MemberPredicate {
+	return func(member swim.Member) bool {
+		labels := make(map[string]string, len(member.Labels))
+		for key, value := range member.Labels {
+			labels[key] = value
+		}
+		return pred(labels)
+	}
+}
+
+// SetMaintenance sets or clears the maintenance label of this node. The
+// state is kept by the factory until cleared, so that it is also applied
+// to a ring joined afterwards
+func (factory *RingpopFactory) SetMaintenance(maintenance bool) error {
+	factory.mutex.Lock()
+	factory.maintenance = maintenance
+	rp := factory.ringpop
+	factory.mutex.Unlock()
+
+	if rp == nil {
+		return nil
+	}
+	return setMaintenanceLabel(rp, maintenance)
+}
+
+// applyMaintenance sets the maintenance label on a newly joined ring
+// when this node is under maintenance
+func (factory *RingpopFactory) applyMaintenance(rp *ringpop.Ringpop) error {
+	factory.mutex.Lock()
+	maintenance := factory.maintenance
+	factory.mutex.Unlock()
+
+	if !maintenance {
+		return nil
+	}
+	return setMaintenanceLabel(rp, true)
+}
+
+// validateLabelLimits checks that the label limits, when
+// set, fit the labels the factory sets on this node
+func validateLabelLimits(rpConfig *Ringpop) error {
+	if rpConfig.TombstonePeriod < 0 || rpConfig.LabelLimitCount < 0 || rpConfig.LabelLimitValueSize < 0 {
+		return fmt.Errorf("ringpop config `tombstonePeriod`, `labelLimitCount` and `labelLimitValueSize` must not be negative")
+	}
+	// the maintenance and partition labels
+	if count := rpConfig.LabelLimitCount; count > 0 && count < 2 {
+		return fmt.Errorf("ringpop config `labelLimitCount` %v leaves no room for the maintenance and partition labels", count)
+	}
+	size := rpConfig.LabelLimitValueSize
+	if size > 0 && (size < len(maintenanceLabelValue) || size < len(rpConfig.Partition)) {
+		return fmt.Errorf("ringpop config `labelLimitValueSize` %v is too small for the maintenance and partition labels", size)
+	}
+	return nil
+}
+
+func setMaintenanceLabel(rp *ringpop.Ringpop, maintenance bool) error {
+	labels, err := rp.Labels()
+	if err != nil {
+		return err
+	}
+	if maintenance {
+		err = labels.Set(MaintenanceLabel, maintenanceLabelValue)
+	} else {
+		_, err = labels.Remove(MaintenanceLabel)
+	}
+	if err != nil {
+		return fmt.Errorf("ringpop failed to update maintenance label: %v", err)
+	}
+	return nil
+}
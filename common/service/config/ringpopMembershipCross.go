@@ -0,0 +1,176 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/uber/ringpop-go/events"
+)
+
+// Direction is the direction in which the member count crosses
+// a threshold registered with OnMembershipCross
+type Direction int
+
+const (
+	// DirectionUp is the member count growing to the threshold or beyond
+	DirectionUp Direction = iota + 1
+	// DirectionDown is the member count shrinking below the threshold
+	DirectionDown
+	// DirectionBoth is the member count crossing the threshold either way
+	DirectionBoth
+)
+
+type (
+	// membershipCrossing is a threshold registered with OnMembershipCross.
+	// above is the side of the threshold last reported and pending is set
+	// while a crossing waits for the debounce
+	membershipCrossing struct {
+		threshold int
+		dir       Direction
+		fn        func(count int)
+		above     bool
+		pending   bool
+	}
+
+	// membershipCrossListener checks the thresholds of the
+	// factory on the ring changed events emitted by ringpop.
+	// Ringpop handles every event in its own goroutine, so the
+	// members are counted and checked under the mutex, which makes
+	// the last check see the latest count whatever the order the
+	// changes are handled in
+	membershipCrossListener struct {
+		factory      *RingpopFactory
+		countMembers func() (int, error)
+
+		mutex sync.Mutex
+	}
+)
+
+// OnMembershipCross registers a callback called with the member count when
+// it crosses the threshold in the given direction: up when the count grows
+// from below the threshold to the threshold or beyond, down when it shrinks
+// back below. The crossing is reported once the count has stayed past the
+// threshold for MembershipCrossDebounce, so a count flapping around the
+// threshold isn't reported. Thresholds may be registered before or after
+// the ring is created, a threshold registered before is crossed relative
+// to an empty ring. Callbacks shouldn't block
+func (factory *RingpopFactory) OnMembershipCross(threshold int, dir Direction, fn func(count int)) error {
+	if threshold <= 0 {
+		return fmt.Errorf("ringpop membership threshold must be positive, got %v", threshold)
+	}
+	if dir != DirectionUp && dir != DirectionDown && dir != DirectionBoth {
+		return fmt.Errorf("ringpop membership crossing direction %v is invalid", dir)
+	}
+	crossing := &membershipCrossing{threshold: threshold, dir: dir, fn: fn}
+	if _, rp := factory.instance(); rp != nil {
+		if count, err := rp.CountReachableMembers(); err == nil {
+			crossing.above = count >= threshold
+		}
+	}
+	factory.mutex.Lock()
+	defer factory.mutex.Unlock()
+	factory.membershipCrossings = append(factory.membershipCrossings, crossing)
+	return nil
+}
+
+// HandleEvent implements ringpop's EventListener interface
+func (l *membershipCrossListener) HandleEvent(event events.Event) {
+	if _, ok := event.(events.RingChangedEvent); !ok {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	count, err := l.countMembers()
+	if err != nil {
+		return
+	}
+	l.factory.checkMembershipCrossings(count, l.countMembers)
+}
+
+// checkMembershipCrossings reports the thresholds the member count crossed,
+// or waits for the debounce before counting the members again to report
+// the crossings that held
+func (factory *RingpopFactory) checkMembershipCrossings(count int, countMembers func() (int, error)) {
+	debounce := factory.config.MembershipCrossDebounce
+	var crossed []*membershipCrossing
+	factory.mutex.Lock()
+	// Stop closes shutdownCh under the mutex, so no debounce is
+	// added to shutdownWG once Stop may be waiting on it
+	stopped := false
+	select {
+	case <-factory.shutdownCh:
+		stopped = true
+	default:
+	}
+	for _, crossing := range factory.membershipCrossings {
+		if (count >= crossing.threshold) == crossing.above || crossing.pending {
+			continue
+		}
+		if debounce > 0 {
+			if stopped {
+				continue
+			}
+			crossing.pending = true
+			factory.shutdownWG.Add(1)
+			go factory.debounceMembershipCrossing(crossing, countMembers)
+			continue
+		}
+		if crossing.cross(count) {
+			crossed = append(crossed, crossing)
+		}
+	}
+	factory.mutex.Unlock()
+
+	for _, crossing := range crossed {
+		crossing.fn(count)
+	}
+}
+
+func (factory *RingpopFactory) debounceMembershipCrossing(crossing *membershipCrossing, countMembers func() (int, error)) {
+	defer factory.shutdownWG.Done()
+	select {
+	case <-factory.shutdownCh:
+		return
+	case <-factory.clock.After(factory.config.MembershipCrossDebounce):
+	}
+	count, err := countMembers()
+
+	factory.mutex.Lock()
+	crossing.pending = false
+	crossed := err == nil && (count >= crossing.threshold) != crossing.above && crossing.cross(count)
+	factory.mutex.Unlock()
+
+	if crossed {
+		crossing.fn(count)
+	}
+}
+
+// cross moves the crossing to the side of the threshold of
+// the count and tells whether the callback must be called
+func (crossing *membershipCrossing) cross(count int) bool {
+	crossing.above = count >= crossing.threshold
+	if crossing.above {
+		return crossing.dir != DirectionDown
+	}
+	return crossing.dir != DirectionUp
+}
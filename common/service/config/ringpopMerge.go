@@ -0,0 +1,77 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"reflect"
+)
+
+// Merge returns a new config layering the override on top of this
+// config: every field set to a non zero value in the override replaces
+// the field of this config. Lists, like the bootstrap hosts, are
+// replaced as a whole rather than appended to, so an override can
+// shrink them. As a consequence an override can neither clear a field
+// nor turn a boolean off. Neither config is modified
+func (rpConfig *Ringpop) Merge(override *Ringpop) *Ringpop {
+	merged := *rpConfig
+	if override != nil {
+		mergedValue := reflect.ValueOf(&merged).Elem()
+		overrideValue := reflect.ValueOf(override).Elem()
+		for i := 0; i < overrideValue.NumField(); i++ {
+			if field := overrideValue.Field(i); !isZeroValue(field) {
+				mergedValue.Field(i).Set(field)
+			}
+		}
+	}
+	merged = merged.clone()
+	return &merged
+}
+
+// clone returns a copy of the config that doesn't share its lists
+func (rpConfig *Ringpop) clone() Ringpop {
+	cloned := *rpConfig
+	cloned.BootstrapHosts = copyStrings(rpConfig.BootstrapHosts)
+	cloned.BootstrapEtcdEndpoints = copyStrings(rpConfig.BootstrapEtcdEndpoints)
+	cloned.BootstrapHostDenyList = copyStrings(rpConfig.BootstrapHostDenyList)
+	cloned.RequiredMembers = copyStrings(rpConfig.RequiredMembers)
+	if rpConfig.MetricTags != nil {
+		cloned.MetricTags = make(map[string]string, len(rpConfig.MetricTags))
+		for key, value := range rpConfig.MetricTags {
+			cloned.MetricTags[key] = value
+		}
+	}
+	return cloned
+}
+
+func copyStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	return append([]string(nil), values...)
+}
+
+func isZeroValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Ptr, reflect.Interface, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	}
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber-common/bark"
+	"github.com/uber-go/tally"
+	"github.com/uber/ringpop-go"
+	"github.com/uber/ringpop-go/events"
+)
+
+const (
+	// bootstrapLatencyMetric is the histogram of the time it takes
+	// to bootstrap ringpop, including discovery and retries
+	bootstrapLatencyMetric = "ringpop.bootstrap.latency"
+	// bootstrapFallbackMetric counts the bootstraps served by a fallback
+	// seed source rather than by discovery, tagged with the source
+	bootstrapFallbackMetric = "ringpop.bootstrap.fallback"
+
+	// membersMetric is the gauge of the reachable members of the ring
+	membersMetric = "ringpop.members"
+	// membersJoinedMetric and membersLeftMetric count the
+	// members added to and removed from the hash ring
+	membersJoinedMetric = "ringpop.members.joined"
+	membersLeftMetric   = "ringpop.members.left"
+
+	bootstrapModeTag     = "mode"
+	bootstrapSourceTag   = "source"
+	bootstrapResultTag   = "result"
+	bootstrapResultOK    = "success"
+	bootstrapResultError = "failure"
+)
+
+// bootstrapLatencyBuckets range from 100ms to about 51s
+var bootstrapLatencyBuckets = tally.MustMakeExponentialDurationBuckets(100*time.Millisecond, 2, 10)
+
+// applyMetricTags adds the static metric tags of the config
+// to the metrics scope and to the logger of the factory
+func (factory *RingpopFactory) applyMetricTags() {
+	tags := factory.config.MetricTags
+	if len(tags) == 0 {
+		return
+	}
+	factory.metricsScope = factory.metricsScope.Tagged(tags)
+	fields := make(bark.Fields, len(tags))
+	for key, value := range tags {
+		fields[key] = value
+	}
+	factory.logger = factory.logger.WithFields(fields)
+}
+
+func validateMetricTags(tags map[string]string) error {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if len(strings.TrimSpace(key)) == 0 {
+			return fmt.Errorf("ringpop config `metricTags` contains an empty tag key")
+		}
+		if len(strings.TrimSpace(tags[key])) == 0 {
+			return fmt.Errorf("ringpop config `metricTags` tag %q has an empty value", key)
+		}
+	}
+	return nil
+}
+
+// recordBootstrapLatency records the bootstrap duration, tagged
+// by bootstrap mode and whether the bootstrap succeeded
+func (factory *RingpopFactory) recordBootstrapLatency(latency time.Duration, err error) {
+	result := bootstrapResultOK
+	if err != nil {
+		result = bootstrapResultError
+	}
+	factory.metricsScope.Tagged(map[string]string{
+		bootstrapModeTag:   factory.bootstrapMode.String(),
+		bootstrapResultTag: result,
+	}).Histogram(bootstrapLatencyMetric, bootstrapLatencyBuckets).RecordDuration(latency)
+}
+
+// membershipMetrics counts the members joining and leaving the ring and,
+// unless the gauge is sampled, updates the members gauge on every change.
+// Ringpop handles every event in its own goroutine, so changes may be
+// handled concurrently and out of order. The members are counted and the
+// gauge updated under the mutex, so the gauge ends up with the latest
+// count rather than the one of the last change handled
+type membershipMetrics struct {
+	factory      *RingpopFactory
+	countMembers func() (int, error)
+
+	mutex sync.Mutex
+}
+
+func newMembershipMetrics(factory *RingpopFactory, rp *ringpop.Ringpop) *membershipMetrics {
+	return &membershipMetrics{
+		factory: factory,
+		countMembers: func() (int, error) {
+			return rp.CountReachableMembers()
+		},
+	}
+}
+
+// HandleEvent implements ringpop's EventListener interface
+func (m *membershipMetrics) HandleEvent(event events.Event) {
+	e, ok := event.(events.RingChangedEvent)
+	if !ok {
+		return
+	}
+	scope := m.factory.metricsScope
+	scope.Counter(membersJoinedMetric).Inc(int64(len(e.ServersAdded)))
+	scope.Counter(membersLeftMetric).Inc(int64(len(e.ServersRemoved)))
+	if m.factory.config.MetricsSampleInterval == 0 {
+		m.updateGauge()
+	}
+}
+
+func (m *membershipMetrics) updateGauge() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	count, err := m.countMembers()
+	if err != nil {
+		return
+	}
+	m.factory.metricsScope.Gauge(membersMetric).Update(float64(count))
+}
+
+// startMembersSampler updates the members gauge every
+// metrics sample interval, when the gauge is sampled
+func (factory *RingpopFactory) startMembersSampler(metrics *membershipMetrics) {
+	if factory.config.MetricsSampleInterval <= 0 {
+		return
+	}
+	factory.shutdownWG.Add(1)
+	go factory.membersSampleLoop(metrics)
+}
+
+func (factory *RingpopFactory) membersSampleLoop(metrics *membershipMetrics) {
+	defer factory.shutdownWG.Done()
+	for {
+		metrics.updateGauge()
+		select {
+		case <-factory.shutdownCh:
+			return
+		case <-factory.clock.After(factory.config.MetricsSampleInterval):
+		}
+	}
+}
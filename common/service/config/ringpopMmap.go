@@ -0,0 +1,181 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// mmapAttempts is how many times the bootstrap file is mapped
+// again when it shrinks or is truncated while it is mapped
+const mmapAttempts = 3
+
+type (
+	// mmapProvider is a discovery provider that memory maps the bootstrap
+	// file instead of reading it, for several processes of a single host,
+	// like a dev or test cluster, sharing a seed file. The mapping is kept
+	// across calls and the file is only parsed again when its size or its
+	// modification time changes. It is mapped again when it changes size
+	// or is replaced. Writers should replace the file with a rename rather
+	// than truncate it in place, which a mapping doesn't survive
+	mmapProvider struct {
+		path   string
+		format string
+	}
+
+	// mappedFile is a bootstrap file mapped with the size in info,
+	// along with the hosts last parsed from it, if any
+	mappedFile struct {
+		file  *os.File
+		info  os.FileInfo
+		data  []byte
+		unmap func() error
+
+		parsed  bool
+		format  string
+		size    int64
+		modTime time.Time
+		hosts   []string
+	}
+)
+
+// mappedFiles holds the bootstrap files mapped by the
+// mmap providers of this process, keyed by their path
+var mappedFiles = struct {
+	sync.Mutex
+	files map[string]*mappedFile
+}{files: make(map[string]*mappedFile)}
+
+func newMmapProvider(path string, format string) *mmapProvider {
+	return &mmapProvider{path: path, format: format}
+}
+
+// Hosts implements discovery.DiscoverProvider
+func (p *mmapProvider) Hosts() ([]string, error) {
+	mappedFiles.Lock()
+	defer mappedFiles.Unlock()
+
+	for attempt := 0; attempt < mmapAttempts; attempt++ {
+		info, err := os.Stat(p.path)
+		if err != nil {
+			releaseMappedFile(p.path)
+			return nil, fmt.Errorf("unable to stat ringpop bootstrap file %v: %v", p.path, err)
+		}
+		if info.Size() == 0 {
+			releaseMappedFile(p.path)
+			return nil, fmt.Errorf("ringpop bootstrap file %v is empty", p.path)
+		}
+		mapped, err := mapFile(p.path, info)
+		if err != nil {
+			return nil, err
+		}
+		if mapped.upToDate(info, p.format) {
+			return copyStrings(mapped.hosts), nil
+		}
+
+		// the mapping is copied to the heap before parsing, so that the
+		// parser never reads a mapping which the file may be truncated under
+		buf, err := copyMapping(mapped.data)
+		if err != nil {
+			releaseMappedFile(p.path)
+			continue
+		}
+		if current, err := mapped.file.Stat(); err != nil || current.Size() < info.Size() {
+			// the file shrank while it was copied, map it again
+			releaseMappedFile(p.path)
+			continue
+		}
+		seeds, err := parseBootstrapFile(p.path, p.format, buf)
+		if err != nil {
+			return nil, err
+		}
+		mapped.parsed, mapped.format, mapped.size, mapped.modTime = true, p.format, info.Size(), info.ModTime()
+		mapped.hosts = seedAddresses(seeds)
+		return copyStrings(mapped.hosts), nil
+	}
+	return nil, fmt.Errorf("ringpop bootstrap file %v kept shrinking while memory mapped", p.path)
+}
+
+// mapFile returns the mapping of the file at path, which is mapped again
+// when the file described by info was replaced or changed size since
+func mapFile(path string, info os.FileInfo) (*mappedFile, error) {
+	if mapped, ok := mappedFiles.files[path]; ok {
+		if os.SameFile(mapped.info, info) && mapped.info.Size() == info.Size() {
+			return mapped, nil
+		}
+		releaseMappedFile(path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open ringpop bootstrap file %v: %v", path, err)
+	}
+	// the file is described again, in case it was replaced since info
+	if info, err = file.Stat(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("unable to stat ringpop bootstrap file %v: %v", path, err)
+	}
+	data, unmap, err := mmapFile(file, int(info.Size()))
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("unable to memory map ringpop bootstrap file %v: %v", path, err)
+	}
+	mapped := &mappedFile{file: file, info: info, data: data, unmap: unmap}
+	mappedFiles.files[path] = mapped
+	return mapped, nil
+}
+
+// releaseMappedFile unmaps and closes the file at path, if it is mapped
+func releaseMappedFile(path string) {
+	mapped, ok := mappedFiles.files[path]
+	if !ok {
+		return
+	}
+	delete(mappedFiles.files, path)
+	mapped.unmap()
+	mapped.file.Close()
+}
+
+// upToDate tells whether the hosts were parsed, with the given
+// format, from the file as described by info
+func (mapped *mappedFile) upToDate(info os.FileInfo, format string) bool {
+	return mapped.parsed && mapped.format == format &&
+		mapped.size == info.Size() && mapped.modTime.Equal(info.ModTime())
+}
+
+// copyMapping copies the mapping to the heap. Reading a mapping past the
+// end of a file truncated in the meantime faults with a SIGBUS, which is
+// turned into a panic recovered here rather than crashing the process
+func copyMapping(data []byte) (buf []byte, err error) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if r := recover(); r != nil {
+			buf, err = nil, fmt.Errorf("memory mapped file faulted: %v", r)
+		}
+	}()
+	buf = make([]byte, len(data))
+	copy(buf, data)
+	return buf, nil
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ringpopNames is the registry of the ringpop names in use within this
+// process by the factories that detect duplicate names. It doesn't see
+// other processes, even on the same host
+var ringpopNames = struct {
+	sync.Mutex
+	names map[string]struct{}
+}{names: make(map[string]struct{})}
+
+// registerName claims the ringpop name of the factory for this
+// process when duplicate names are detected
+func (factory *RingpopFactory) registerName() error {
+	if !factory.config.DetectDuplicateName {
+		return nil
+	}
+	ringpopNames.Lock()
+	defer ringpopNames.Unlock()
+
+	factory.mutex.Lock()
+	defer factory.mutex.Unlock()
+	if factory.nameRegistered {
+		return nil
+	}
+	name := factory.config.Name
+	if _, ok := ringpopNames.names[name]; ok {
+		return fmt.Errorf("ringpop name %v is already in use within this process", name)
+	}
+	ringpopNames.names[name] = struct{}{}
+	factory.nameRegistered = true
+	return nil
+}
+
+// releaseName releases the ringpop name claimed by the factory, if any
+func (factory *RingpopFactory) releaseName() {
+	ringpopNames.Lock()
+	defer ringpopNames.Unlock()
+
+	factory.mutex.Lock()
+	defer factory.mutex.Unlock()
+	if factory.nameRegistered {
+		delete(ringpopNames.names, factory.config.Name)
+		factory.nameRegistered = false
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultNomadAddress is the nomad agent address used when neither
+	// the config nor the NOMAD_ADDR environment variable sets one
+	defaultNomadAddress = "http://127.0.0.1:4646"
+
+	nomadClientStatusRunning = "running"
+)
+
+type (
+	// nomadProvider is a discovery provider returning the addresses of
+	// the running and healthy allocations of a nomad job. The timeout
+	// bounds the listing along with the reads of all the allocations
+	nomadProvider struct {
+		address string
+		job     string
+		port    string
+		token   string
+		timeout time.Duration
+		client  *http.Client
+	}
+
+	nomadAllocationStub struct {
+		ID               string
+		ClientStatus     string
+		DeploymentStatus *struct {
+			Healthy *bool
+		}
+	}
+
+	nomadAllocation struct {
+		Resources *struct {
+			Networks []nomadNetwork
+		}
+		AllocatedResources *struct {
+			Shared struct {
+				Networks []nomadNetwork
+			}
+		}
+	}
+
+	nomadNetwork struct {
+		IP            string
+		ReservedPorts []nomadPort
+		DynamicPorts  []nomadPort
+	}
+
+	nomadPort struct {
+		Label string
+		Value int
+	}
+)
+
+// newNomadProvider creates a nomad provider, port is either
+// a port number or the label of a port of the job's network
+func newNomadProvider(address string, job string, port string, timeout time.Duration) *nomadProvider {
+	if len(address) == 0 {
+		address = os.Getenv("NOMAD_ADDR")
+	}
+	if len(address) == 0 {
+		address = defaultNomadAddress
+	}
+	return &nomadProvider{
+		address: address,
+		job:     job,
+		port:    port,
+		token:   os.Getenv("NOMAD_TOKEN"),
+		timeout: timeout,
+		client:  &http.Client{},
+	}
+}
+
+// Hosts implements discovery.DiscoverProvider. An allocation that can't
+// be read, like one stopped since the listing, is skipped, the call only
+// fails when none of the allocations could be read
+func (p *nomadProvider) Hosts() ([]string, error) {
+	ctx := context.Background()
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+	var stubs []nomadAllocationStub
+	if err := p.get(ctx, "/v1/job/"+url.PathEscape(p.job)+"/allocations", &stubs); err != nil {
+		return nil, fmt.Errorf("ringpop nomad discovery for job %v: %v", p.job, err)
+	}
+	var hosts, failed []string
+	var lastErr error
+	for _, stub := range stubs {
+		if !stub.usable() {
+			continue
+		}
+		var alloc nomadAllocation
+		if err := p.get(ctx, "/v1/allocation/"+url.PathEscape(stub.ID), &alloc); err != nil {
+			failed = append(failed, stub.ID)
+			lastErr = err
+			continue
+		}
+		if host, ok := alloc.hostPort(p.port); ok {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) == 0 && lastErr != nil {
+		return nil, fmt.Errorf("ringpop nomad discovery for job %v failed to read allocations %v: %v",
+			p.job, strings.Join(failed, ", "), lastErr)
+	}
+	return hosts, nil
+}
+
+func (p *nomadProvider) get(ctx context.Context, path string, result interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, p.address+path, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if len(p.token) > 0 {
+		req.Header.Set("X-Nomad-Token", p.token)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %v returned %v", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// usable returns true for running allocations
+// that aren't reported unhealthy by a deployment
+func (stub nomadAllocationStub) usable() bool {
+	if stub.ClientStatus != nomadClientStatusRunning {
+		return false
+	}
+	status := stub.DeploymentStatus
+	return status == nil || status.Healthy == nil || *status.Healthy
+}
+
+// hostPort returns the ip:port of the allocation for the given port
+func (alloc nomadAllocation) hostPort(port string) (string, bool) {
+	var networks []nomadNetwork
+	if alloc.Resources != nil {
+		networks = append(networks, alloc.Resources.Networks...)
+	}
+	if alloc.AllocatedResources != nil {
+		networks = append(networks, alloc.AllocatedResources.Shared.Networks...)
+	}
+	for _, network := range networks {
+		if len(network.IP) == 0 {
+			continue
+		}
+		if _, err := strconv.Atoi(port); err == nil {
+			return net.JoinHostPort(network.IP, port), true
+		}
+		for _, ports := range [][]nomadPort{network.ReservedPorts, network.DynamicPorts} {
+			for _, p := range ports {
+				if p.Label == port {
+					return net.JoinHostPort(network.IP, strconv.Itoa(p.Value)), true
+				}
+			}
+		}
+	}
+	return "", false
+}
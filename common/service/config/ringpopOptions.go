@@ -0,0 +1,147 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"time"
+
+	"github.com/uber-common/bark"
+	"github.com/uber-go/tally"
+	"github.com/uber/ringpop-go"
+	"github.com/uber/ringpop-go/discovery"
+)
+
+type (
+	// FactoryOption configures optional behavior of a RingpopFactory
+	FactoryOption func(*RingpopFactory)
+
+	// AddressTransform translates a host:port, like a network address
+	// translation that depends on the environment
+	AddressTransform func(addr string) (string, error)
+
+	// SeedScorer scores a seed host:port, seeds with a higher score
+	// are handed to ringpop first
+	SeedScorer func(addr string) float64
+
+	// SeedListTransform rewrites the list of seeds
+	SeedListTransform func(hosts []string) ([]string, error)
+
+	// SeedValidator checks a list of seeds before it is trusted
+	SeedValidator func(hosts []string) error
+
+	// Clock is the source of time for the timeouts, retries and
+	// background loops of the factory. Mainly used for unit testing
+	Clock interface {
+		Now() time.Time
+		After(d time.Duration) <-chan time.Time
+	}
+
+	realClock struct{}
+)
+
+// Now implements Clock
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// After implements Clock
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// WithLogger sets the logger used by the factory
+func WithLogger(logger bark.Logger) FactoryOption {
+	return func(factory *RingpopFactory) {
+		factory.logger = logger
+	}
+}
+
+// WithMetrics sets the metrics scope the factory emits to
+func WithMetrics(scope tally.Scope) FactoryOption {
+	return func(factory *RingpopFactory) {
+		factory.metricsScope = scope
+	}
+}
+
+// WithProvider sets the discovery provider used to bootstrap
+// ringpop, it takes precedence over the configured bootstrap
+// source the same way a custom discovery provider does
+func WithProvider(provider discovery.DiscoverProvider) FactoryOption {
+	return func(factory *RingpopFactory) {
+		factory.provider = provider
+	}
+}
+
+// WithClock sets the clock used by the factory, defaults to the real clock
+func WithClock(clock Clock) FactoryOption {
+	return func(factory *RingpopFactory) {
+		factory.clock = clock
+	}
+}
+
+// WithAddressTransform sets a transform applied to the discovered
+// seeds and to the address this node advertises to the ring
+func WithAddressTransform(transform AddressTransform) FactoryOption {
+	return func(factory *RingpopFactory) {
+		factory.addressTransform = transform
+	}
+}
+
+// WithSeedListTransform sets a transform applied to the seeds after all of
+// the built in processing, right before they are joined. An error aborts
+// the bootstrap attempt, while an empty list bootstraps this node alone,
+// subject to the self only bootstrap policy
+func WithSeedListTransform(transform SeedListTransform) FactoryOption {
+	return func(factory *RingpopFactory) {
+		factory.seedListTransform = transform
+	}
+}
+
+// WithSeedValidator sets a check of the seeds, like their count being in
+// an expected range, run on the final list of seeds of each bootstrap
+// attempt and reconciliation. An error fails the resolution of the seeds
+func WithSeedValidator(validator SeedValidator) FactoryOption {
+	return func(factory *RingpopFactory) {
+		factory.seedValidator = validator
+	}
+}
+
+// WithRingpopOptions sets ringpop options appended after the ones derived
+// from the config when ringpop is created, giving access to any option of
+// the library. This is an advanced escape hatch: the options are not
+// validated, and since the later option wins, they silently override
+// the config derived ones they conflict with, like ringpop.Channel or
+// ringpop.SuspectPeriod. Prefer a config param when there is one
+func WithRingpopOptions(opts ...ringpop.Option) FactoryOption {
+	return func(factory *RingpopFactory) {
+		factory.ringpopOptionsExtra = append(factory.ringpopOptionsExtra, opts...)
+	}
+}
+
+// WithSeedScorer sets a scorer the discovered seeds are sorted by, in
+// descending order, with ties keeping the discovered order. Seeds are
+// scored when they are resolved, so the order only follows live
+// conditions, like latency or load, as far as the seeds are refreshed
+func WithSeedScorer(scorer SeedScorer) FactoryOption {
+	return func(factory *RingpopFactory) {
+		factory.seedScorer = scorer
+	}
+}
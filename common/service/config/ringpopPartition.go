@@ -0,0 +1,75 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"fmt"
+
+	"github.com/uber/ringpop-go"
+	"github.com/uber/ringpop-go/swim"
+)
+
+const (
+	// partitionLabel is the ringpop label carrying the partition of a member
+	partitionLabel = "partition"
+	// maxPartitionLength is the default ringpop limit on label values
+	maxPartitionLength = 128
+)
+
+// validatePartition checks that the partition can be used as a label value
+func validatePartition(partition string) error {
+	if len(partition) > maxPartitionLength {
+		return fmt.Errorf("ringpop partition %q is longer than %v characters", partition, maxPartitionLength)
+	}
+	if !validRingpopName.MatchString(partition) {
+		return fmt.Errorf("ringpop partition %q contains invalid characters", partition)
+	}
+	return nil
+}
+
+// setPartition labels this node with the configured partition, if any.
+// Labels can only be set once ringpop is bootstrapped
+func (factory *RingpopFactory) setPartition(rp *ringpop.Ringpop) error {
+	if len(factory.config.Partition) == 0 {
+		return nil
+	}
+	labels, err := rp.Labels()
+	if err != nil {
+		return err
+	}
+	if err := labels.Set(partitionLabel, factory.config.Partition); err != nil {
+		return fmt.Errorf("ringpop failed to set partition label: %v", err)
+	}
+	return nil
+}
+
+// MembersInPartition returns the reachable members of the ring labeled with
+// the given partition, members without a partition label are left out
+func (factory *RingpopFactory) MembersInPartition(partition string) ([]string, error) {
+	if err := validatePartition(partition); err != nil {
+		return nil, err
+	}
+	_, rp := factory.instance()
+	if rp == nil {
+		return nil, ErrRingpopNotCreated
+	}
+	return rp.GetReachableMembers(swim.MemberWithLabelAndValue(partitionLabel, partition))
+}
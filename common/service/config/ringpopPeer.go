@@ -0,0 +1,124 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/uber/ringpop-go/swim"
+	tcg "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/json"
+)
+
+const (
+	// ringpopServiceName and ringpopAdminStatsEndpoint are where
+	// every ringpop member serves its view of the membership
+	ringpopServiceName        = "ringpop"
+	ringpopAdminStatsEndpoint = "/admin/stats"
+	// serviceNameLabel is the ringpop label carrying the cadence service of
+	// a member, it mirrors membership.RoleKey
+	serviceNameLabel = "serviceName"
+)
+
+type (
+	// peerProvider is a discovery provider returning the alive
+	// members of the ring as seen by a coordinator member, only
+	// those of the given cadence service when there is one
+	peerProvider struct {
+		appName string
+		peer    string
+		param   string
+		service string
+		timeout time.Duration
+	}
+
+	// peerStats is the part of the ringpop admin stats that holds the membership
+	peerStats struct {
+		Membership struct {
+			Checksum uint32       `json:"checksum"`
+			Members  []peerMember `json:"members"`
+		} `json:"membership"`
+	}
+
+	peerMember struct {
+		Address     string            `json:"address"`
+		Status      string            `json:"status"`
+		Incarnation int64             `json:"incarnationNumber"`
+		Labels      map[string]string `json:"labels"`
+	}
+)
+
+func newPeerProvider(appName string, peer string, timeout time.Duration) *peerProvider {
+	return &peerProvider{
+		appName: appName,
+		peer:    peer,
+		param:   "bootstrapPeer",
+		timeout: timeout,
+	}
+}
+
+// newFrontendProvider returns a provider of the members of the given
+// service, as seen by the ringpop of the cadence frontend at address,
+// which shares the tchannel the frontend api is served on
+func newFrontendProvider(appName string, address string, service string, timeout time.Duration) *peerProvider {
+	return &peerProvider{
+		appName: appName,
+		peer:    address,
+		param:   "bootstrapFrontendAddress",
+		service: service,
+		timeout: timeout,
+	}
+}
+
+// Hosts implements discovery.DiscoverProvider
+func (p *peerProvider) Hosts() ([]string, error) {
+	ch, err := tcg.NewChannel(p.appName+"-bootstrap", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+
+	ctx, cancel := json.NewContext(p.timeout)
+	defer cancel()
+	var stats peerStats
+	client := json.NewClient(ch, ringpopServiceName, &json.ClientOptions{HostPort: p.peer})
+	if err := client.Call(ctx, ringpopAdminStatsEndpoint, nil, &stats); err != nil {
+		return nil, fmt.Errorf("ringpop bootstrap peer %v is unreachable, `%v` must point at a live member: %v", p.peer, p.param, err)
+	}
+	return stats.aliveMembers(p.service), nil
+}
+
+// aliveMembers returns the address of the alive members, only
+// those labeled with the given service when it isn't empty
+func (stats *peerStats) aliveMembers(service string) []string {
+	var hosts []string
+	for _, member := range stats.Membership.Members {
+		if member.Status != swim.Alive {
+			continue
+		}
+		if len(service) > 0 && member.Labels[serviceNameLabel] != service {
+			continue
+		}
+		hosts = append(hosts, member.Address)
+	}
+	return hosts
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	tcg "github.com/uber/tchannel-go"
+)
+
+// deriveSeedPorts appends the port of the channel to the seeds without
+// one, when the config asks for it
+func (factory *RingpopFactory) deriveSeedPorts(ch *tcg.Channel, hosts []string) ([]string, error) {
+	if !factory.config.DerivePortFromChannel {
+		return hosts, nil
+	}
+	port, err := channelPort(ch.PeerInfo().HostPort)
+	if err != nil {
+		return nil, err
+	}
+	return derivePorts(hosts, port), nil
+}
+
+// channelPort returns the port the channel listens on, from the host:port
+// of its peer info. A channel that doesn't listen yet, or listens on a
+// wildcard port, has no port seeds can be derived from
+func channelPort(hostPort string) (string, error) {
+	_, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return "", fmt.Errorf("ringpop can't derive the seed port from the channel address %q: %v", hostPort, err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 0 || p > 65535 {
+		return "", fmt.Errorf("ringpop can't derive the seed port from the channel address %q: invalid port", hostPort)
+	}
+	if p == 0 {
+		return "", fmt.Errorf("ringpop can't derive the seed port from the channel address %q: the channel is bound to a wildcard port", hostPort)
+	}
+	return port, nil
+}
+
+// derivePorts appends the port to the seeds without one,
+// the seeds with a port are left as is
+func derivePorts(hosts []string, port string) []string {
+	derived := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		if hasPort(host) {
+			derived = append(derived, host)
+			continue
+		}
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+		derived = append(derived, net.JoinHostPort(host, port))
+	}
+	return derived
+}
+
+// hasPort tells whether the seed is a host:port, as opposed
+// to a bare hostname, ipv4 or ipv6 address
+func hasPort(host string) bool {
+	if net.ParseIP(host) != nil {
+		// a bare ipv6 address has colons but no port
+		return false
+	}
+	_, _, err := net.SplitHostPort(host)
+	return err == nil
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"sync"
+
+	"github.com/uber/ringpop-go/discovery"
+)
+
+// preparedProvider is the discovery provider ringpop bootstraps with. It
+// returns the prepared seeds on the first call, then discovers the seeds
+// afresh, since swim keeps the provider for its join retries and its
+// partition healer, which must see the live seeds rather than a snapshot
+type preparedProvider struct {
+	mutex    sync.Mutex
+	hosts    []string
+	served   bool
+	discover func() ([]string, error)
+}
+
+// discoverProvider returns the provider of the prepared seeds
+// followed by the live seeds of the factory
+func (prepared *PreparedBootstrap) discoverProvider() discovery.DiscoverProvider {
+	factory := prepared.factory
+	return &preparedProvider{
+		hosts: prepared.hosts,
+		discover: func() ([]string, error) {
+			provider, err := factory.discoveryProvider()
+			if err != nil {
+				return nil, err
+			}
+			hosts, err := provider.Hosts()
+			if err != nil {
+				return nil, err
+			}
+			return factory.deriveSeedPorts(prepared.channel, hosts)
+		},
+	}
+}
+
+// Hosts implements discovery.DiscoverProvider
+func (p *preparedProvider) Hosts() ([]string, error) {
+	p.mutex.Lock()
+	served := p.served
+	p.served = true
+	p.mutex.Unlock()
+	if !served {
+		return p.hosts, nil
+	}
+	return p.discover()
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"sync"
+	"time"
+
+	"github.com/uber-common/bark"
+	"github.com/uber/ringpop-go/events"
+	"github.com/uber/ringpop-go/swim"
+)
+
+// bootstrapProgress tracks the progress of a ringpop
+// bootstrap from the join events emitted by swim
+type bootstrapProgress struct {
+	seeds   int
+	started time.Time
+
+	mutex        sync.Mutex
+	joinAttempts int
+	joined       int
+}
+
+func newBootstrapProgress(seeds int, started time.Time) *bootstrapProgress {
+	return &bootstrapProgress{
+		seeds:   seeds,
+		started: started,
+	}
+}
+
+// HandleEvent implements ringpop's EventListener interface
+func (p *bootstrapProgress) HandleEvent(event events.Event) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	switch e := event.(type) {
+	case swim.JoinTriesUpdateEvent:
+		p.joinAttempts = e.Retries
+	case swim.JoinCompleteEvent:
+		p.joined = e.NumJoined
+	}
+}
+
+func (p *bootstrapProgress) fields(now time.Time) bark.Fields {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return bark.Fields{
+		"seeds":        p.seeds,
+		"joinAttempts": p.joinAttempts,
+		"joined":       p.joined,
+		"elapsed":      now.Sub(p.started),
+	}
+}
+
+// logBootstrapProgress logs the bootstrap progress every
+// bootstrap progress interval until doneC is closed
+func (factory *RingpopFactory) logBootstrapProgress(progress *bootstrapProgress, doneC <-chan struct{}) {
+	for {
+		select {
+		case <-doneC:
+			return
+		case <-factory.clock.After(factory.config.BootstrapProgressInterval):
+			factory.bootstrapLogger().WithFields(progress.fields(factory.clock.Now())).Info("Ringpop is still bootstrapping")
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/uber/ringpop-go/discovery"
+	"golang.org/x/time/rate"
+)
+
+// discoverySlots bounds the discoveries in flight across all of
+// the factories of the process, nil slots means no bound
+var discoverySlots = struct {
+	sync.RWMutex
+	slots chan struct{}
+}{}
+
+// SetMaxConcurrentDiscovery bounds the number of seed discoveries in flight
+// across all of the factories of the process, like those of a multi ring
+// process or of a test suite, to spare a shared discovery source. Zero
+// removes the bound, which is the default
+func SetMaxConcurrentDiscovery(n int) error {
+	if n < 0 {
+		return fmt.Errorf("ringpop max concurrent discovery must not be negative, got %v", n)
+	}
+	discoverySlots.Lock()
+	defer discoverySlots.Unlock()
+	discoverySlots.slots = nil
+	if n > 0 {
+		discoverySlots.slots = make(chan struct{}, n)
+	}
+	return nil
+}
+
+// acquireDiscoverySlot waits for a discovery slot, for at most the max join
+// duration, and returns the function releasing it. Discoveries started
+// before the bound changed release the slot they acquired
+func (factory *RingpopFactory) acquireDiscoverySlot(ctx context.Context) (func(), error) {
+	discoverySlots.RLock()
+	slots := discoverySlots.slots
+	discoverySlots.RUnlock()
+	if slots == nil {
+		return func() {}, nil
+	}
+
+	release := func() { <-slots }
+	select {
+	case slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+	timeout := factory.config.MaxJoinDuration
+	select {
+	case slots <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-factory.clock.After(timeout):
+		return nil, fmt.Errorf("ringpop discovery waited %v for one of the %v concurrent discovery slots", timeout, cap(slots))
+	}
+}
+
+// rateLimitedProvider is a discovery provider that smooths the
+// calls to the underlying provider with a token bucket
+type rateLimitedProvider struct {
+	provider discovery.DiscoverProvider
+	limiter  *rate.Limiter
+	clock    Clock
+	timeout  time.Duration
+}
+
+// Hosts implements discovery.DiscoverProvider, it fails right
+// away when the call would be delayed beyond the timeout
+func (p *rateLimitedProvider) Hosts() ([]string, error) {
+	now := p.clock.Now()
+	reservation := p.limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return nil, fmt.Errorf("ringpop discovery rate limited, burst is zero")
+	}
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		if delay > p.timeout {
+			reservation.CancelAt(now)
+			return nil, fmt.Errorf("ringpop discovery rate limited, next call allowed in %v which exceeds the %v timeout", delay, p.timeout)
+		}
+		<-p.clock.After(delay)
+	}
+	return p.provider.Hosts()
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/uber-common/bark"
+	"github.com/uber/cadence/common/backoff"
+	"github.com/uber/cadence/common/logging"
+	"github.com/uber/ringpop-go"
+)
+
+// reconcileJitterCoefficient spreads reconciliation
+// across the fleet so that nodes don't sync up
+const reconcileJitterCoefficient = 0.2
+
+// startReconciler starts the membership reconciliation
+// loop, if a reconcile interval is configured
+func (factory *RingpopFactory) startReconciler(rp *ringpop.Ringpop) {
+	if factory.config.ReconcileInterval <= 0 {
+		return
+	}
+	factory.shutdownWG.Add(1)
+	go factory.reconcileLoop(rp)
+}
+
+func (factory *RingpopFactory) reconcileLoop(rp *ringpop.Ringpop) {
+	defer factory.shutdownWG.Done()
+
+	jitter := backoff.NewJitter()
+	interval := factory.config.ReconcileInterval
+	for {
+		select {
+		case <-factory.shutdownCh:
+			return
+		case <-factory.clock.After(jitter.JitDuration(interval, reconcileJitterCoefficient)):
+			err := factory.reconcile(rp)
+			interval = nextReconcileInterval(interval, factory.config.ReconcileInterval, factory.config.DiscoveryRefreshMaxInterval, err != nil)
+			if err != nil {
+				factory.logger.WithFields(bark.Fields{
+					logging.TagErr: err,
+					"nextInterval": interval,
+				}).Warn("Ringpop reconciliation failed to discover seeds")
+			}
+		}
+	}
+}
+
+// nextReconcileInterval doubles the interval after a failed discovery, up
+// to the max interval, and resets it to the base interval after a success.
+// A zero max interval keeps the interval fixed
+func nextReconcileInterval(current time.Duration, base time.Duration, max time.Duration, failed bool) time.Duration {
+	if !failed || max <= base {
+		return base
+	}
+	if current >= max/2 {
+		return max
+	}
+	return current * 2
+}
+
+// reconcile compares the ring membership against a freshly discovered
+// seed list and gossips to the seeds when too few of them are members.
+// It only returns the discovery errors, other failures are logged
+func (factory *RingpopFactory) reconcile(rp *ringpop.Ringpop) error {
+	provider, err := factory.discoveryProvider()
+	if err != nil {
+		return err
+	}
+	seeds, err := provider.Hosts()
+	if err != nil {
+		return err
+	}
+	self, err := rp.WhoAmI()
+	if err != nil {
+		factory.logger.WithFields(bark.Fields{logging.TagErr: err}).Warn("Ringpop reconciliation failed to get self address")
+		return nil
+	}
+	members, err := rp.GetReachableMembers()
+	if err != nil {
+		factory.logger.WithFields(bark.Fields{logging.TagErr: err}).Warn("Ringpop reconciliation failed to get members")
+		return nil
+	}
+
+	overlap, ok := membershipOverlap(members, seeds, self)
+	if !ok || overlap >= factory.config.ReconcileMinOverlap {
+		return nil
+	}
+
+	logger := factory.logger.WithFields(bark.Fields{
+		"overlap":    overlap,
+		"minOverlap": factory.config.ReconcileMinOverlap,
+		"members":    len(members),
+		"seeds":      len(seeds),
+	})
+	logger.Warn("Ringpop membership drifted from discovered seeds, rejoining")
+
+	ch, _ := factory.instance()
+	if err := factory.nudgeSeeds(context.Background(), ch, rp, seeds); err != nil {
+		logger.WithFields(bark.Fields{logging.TagErr: err}).Error("Ringpop reconciliation rejoin failed")
+	}
+	return nil
+}
+
+// AddSeeds makes the ring contact the given seeds right away rather
+// than waiting for gossip to reach them, which speeds up convergence
+// when new nodes are known before gossip does. The valid seeds are
+// contacted even when some are not host:port, and the returned error
+// lists the seeds that could not be added
+func (factory *RingpopFactory) AddSeeds(hosts []string) error {
+	ch, rp := factory.instance()
+	if rp == nil {
+		return ErrRingpopNotCreated
+	}
+	var valid, invalid []string
+	for _, host := range hosts {
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			invalid = append(invalid, host)
+			continue
+		}
+		valid = append(valid, host)
+	}
+	if len(valid) > 0 {
+		if err := factory.nudgeSeeds(context.Background(), ch, rp, valid); err != nil {
+			return fmt.Errorf("ringpop failed to add seeds %v: %v", strings.Join(hosts, ", "), err)
+		}
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("ringpop failed to add seeds that are not host:port: %v", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// membershipOverlap returns the fraction of seeds, other than self, that
+// are members of the ring. It returns false when there are no such seeds.
+func membershipOverlap(members []string, seeds []string, self string) (float64, bool) {
+	present := make(map[string]struct{}, len(members))
+	for _, member := range members {
+		present[normalizeHost(member, addressNormalizationIPv4)] = struct{}{}
+	}
+	self = normalizeHost(self, addressNormalizationIPv4)
+
+	total, found := 0, 0
+	for _, seed := range dedupHosts(normalizeHosts(seeds, addressNormalizationIPv4)) {
+		if seed == self {
+			continue
+		}
+		total++
+		if _, ok := present[seed]; ok {
+			found++
+		}
+	}
+	if total == 0 {
+		return 0, false
+	}
+	return float64(found) / float64(total), true
+}
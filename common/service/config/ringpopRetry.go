@@ -0,0 +1,177 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/uber-common/bark"
+	"github.com/uber/cadence/common/backoff"
+	"github.com/uber/cadence/common/logging"
+)
+
+const (
+	retryLimitAttempts = "bootstrapRetryAttempts"
+	retryLimitElapsed  = "bootstrapRetryMaxElapsed"
+)
+
+// BootstrapRetryError is returned when the bootstrap retries run out, Limit
+// is the config param, either bootstrapRetryAttempts or bootstrapRetryMaxElapsed,
+// that stopped them and Err is the error of the last attempt
+type BootstrapRetryError struct {
+	Limit    string
+	Attempts int
+	Elapsed  time.Duration
+	Err      error
+}
+
+// Error implements error
+func (e *BootstrapRetryError) Error() string {
+	return fmt.Sprintf("ringpop bootstrap failed after %v attempts in %v, `%v` reached: %v", e.Attempts, e.Elapsed, e.Limit, e.Err)
+}
+
+// SelfOnlyDiscoveryError is returned when the bootstrap is aborted because
+// the seeds of Attempts consecutive attempts were nothing but this node, Self.
+// Unlike running out of retries, it points at a discovery that is likely
+// misconfigured, like a selector or a dns name matching this node only
+type SelfOnlyDiscoveryError struct {
+	Self     string
+	Attempts int
+}
+
+// Error implements error
+func (e *SelfOnlyDiscoveryError) Error() string {
+	return fmt.Sprintf("ringpop bootstrap aborted, discovery resolved to this node %v alone on %v consecutive attempts, "+
+		"it is likely misconfigured, see `selfOnlyAbortThreshold`", e.Self, e.Attempts)
+}
+
+// selfOnlyAbort counts the consecutive bootstrap attempts whose seeds
+// are nothing but this node, up to the abort threshold
+type selfOnlyAbort struct {
+	threshold   int
+	consecutive int
+}
+
+func (factory *RingpopFactory) newSelfOnlyAbort() *selfOnlyAbort {
+	if factory.config.FirstNode {
+		return &selfOnlyAbort{}
+	}
+	return &selfOnlyAbort{threshold: factory.config.SelfOnlyAbortThreshold}
+}
+
+// check records the seeds of an attempt and returns a SelfOnlyDiscoveryError
+// once the threshold of consecutive self only attempts is reached
+func (a *selfOnlyAbort) check(hosts []string, self string) error {
+	if a.threshold <= 0 {
+		return nil
+	}
+	key := membershipKey(self)
+	for _, host := range hosts {
+		if membershipKey(host) != key {
+			a.consecutive = 0
+			return nil
+		}
+	}
+	a.consecutive++
+	if a.consecutive < a.threshold {
+		return nil
+	}
+	return &SelfOnlyDiscoveryError{Self: self, Attempts: a.consecutive}
+}
+
+// retryBootstrap runs the bootstrap operation, retrying it with an
+// exponential backoff for as long as it fails with a retryable error
+// and neither the configured number of retries nor the max elapsed
+// time is exhausted
+func (factory *RingpopFactory) retryBootstrap(ctx context.Context, operation backoff.Operation) error {
+	maxAttempts := factory.config.BootstrapRetryAttempts
+	maxElapsed := factory.config.BootstrapRetryMaxElapsed
+	if maxAttempts == 0 && maxElapsed == 0 {
+		return operation()
+	}
+
+	policy := backoff.NewExponentialRetryPolicy(factory.config.BootstrapRetryInterval)
+	policy.SetMaximumAttempts(maxAttempts)
+	policy.SetExpirationInterval(maxElapsed)
+	if factory.config.BootstrapRetryMaxInterval > 0 {
+		policy.SetMaximumInterval(factory.config.BootstrapRetryMaxInterval)
+	}
+	start := factory.clock.Now()
+	retrier := backoff.NewRetrier(policy, factory.clock)
+
+	for attempt := 1; ; attempt++ {
+		err := operation()
+		if err == nil || !isRetryableBootstrapError(err) {
+			return err
+		}
+		next := retrier.NextBackOff()
+		if next < 0 {
+			limit := retryLimitElapsed
+			if maxAttempts > 0 && attempt > maxAttempts {
+				limit = retryLimitAttempts
+			}
+			return &BootstrapRetryError{
+				Limit:    limit,
+				Attempts: attempt,
+				Elapsed:  factory.clock.Now().Sub(start),
+				Err:      err,
+			}
+		}
+		factory.bootstrapLogger().WithFields(bark.Fields{
+			logging.TagErr: err,
+			"attempt":      attempt,
+			"backoff":      next,
+		}).Warn("Ringpop bootstrap failed, retrying")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-factory.clock.After(next):
+		}
+	}
+}
+
+// isRetryableBootstrapError returns false for the errors that won't go
+// away by retrying the bootstrap, a composite discovery error is only
+// retried when at least one of its sources may succeed on a retry
+func isRetryableBootstrapError(err error) bool {
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return false
+	}
+	if dnsErr, ok := err.(*DNSLookupError); ok {
+		return dnsErr.Temporary
+	}
+	if _, ok := err.(*SelfOnlyDiscoveryError); ok {
+		return false
+	}
+	if compositeErr, ok := err.(*CompositeDiscoveryError); ok {
+		// worth retrying unless every source failed for good
+		for _, cause := range compositeErr.Causes {
+			if isRetryableBootstrapError(cause) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"github.com/uber/ringpop-go/events"
+)
+
+type (
+	// RingChange describes a change of the hash ring, the keys owned by the
+	// added and removed members, and by their neighbors, may have moved
+	RingChange struct {
+		Added   []string
+		Updated []string
+		Removed []string
+	}
+
+	// RingChangeHandler is called with every change of the hash ring
+	RingChangeHandler func(change RingChange)
+
+	// ringChangeListener forwards the ring changed events
+	// emitted by ringpop to the handlers of the factory
+	ringChangeListener struct {
+		factory *RingpopFactory
+	}
+)
+
+// OnRingChange registers a handler called whenever the hash ring changes,
+// including while the ring is joined. It may be registered before or after
+// the ring is created. Ringpop emits every change from its own goroutine,
+// so handlers may be called concurrently and successive changes may be
+// delivered out of order. The handlers of a single change are called in
+// the order they were registered. Handlers that need the current ring
+// should look it up rather than replay the changes, and shouldn't block
+func (factory *RingpopFactory) OnRingChange(handler RingChangeHandler) {
+	factory.mutex.Lock()
+	defer factory.mutex.Unlock()
+	factory.ringChangeHandlers = append(factory.ringChangeHandlers, handler)
+}
+
+// HandleEvent implements ringpop's EventListener interface
+func (l *ringChangeListener) HandleEvent(event events.Event) {
+	e, ok := event.(events.RingChangedEvent)
+	if !ok {
+		return
+	}
+	l.factory.mutex.Lock()
+	handlers := l.factory.ringChangeHandlers
+	l.factory.mutex.Unlock()
+
+	change := RingChange{
+		Added:   e.ServersAdded,
+		Updated: e.ServersUpdated,
+		Removed: e.ServersRemoved,
+	}
+	for _, handler := range handlers {
+		handler(change)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+type (
+	// s3ObjectFetcher returns the content of an s3 object
+	s3ObjectFetcher func(ctx context.Context, region string, bucket string, key string) ([]byte, error)
+
+	// s3Provider is a discovery provider that reads the seeds from an
+	// s3 object, which has the format of the bootstrap file
+	s3Provider struct {
+		bucket  string
+		key     string
+		region  string
+		format  string
+		timeout time.Duration
+		fetch   s3ObjectFetcher
+	}
+)
+
+func newS3Provider(bucket string, key string, region string, format string, timeout time.Duration) *s3Provider {
+	return &s3Provider{
+		bucket:  bucket,
+		key:     key,
+		region:  region,
+		format:  format,
+		timeout: timeout,
+		fetch:   fetchS3Object,
+	}
+}
+
+// Seeds returns the annotated seeds listed in the s3 object
+func (p *s3Provider) Seeds() ([]Seed, error) {
+	ctx := context.Background()
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+	data, err := p.fetch(ctx, p.region, p.bucket, p.key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read ringpop bootstrap object s3://%v/%v: %v", p.bucket, p.key, err)
+	}
+	seeds, err := seedParser(p.format)(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse ringpop bootstrap object s3://%v/%v: %v", p.bucket, p.key, err)
+	}
+	return seeds, nil
+}
+
+// Hosts implements discovery.DiscoverProvider
+func (p *s3Provider) Hosts() ([]string, error) {
+	seeds, err := p.Seeds()
+	if err != nil {
+		return nil, err
+	}
+	return seedAddresses(seeds), nil
+}
+
+// fetchS3Object reads an s3 object using the default aws credential chain
+func fetchS3Object(ctx context.Context, region string, bucket string, key string) ([]byte, error) {
+	opts := session.Options{SharedConfigState: session.SharedConfigEnable}
+	if len(region) > 0 {
+		opts.Config.Region = aws.String(region)
+	}
+	sess, err := session.NewSessionWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	out, err := s3.New(sess).GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+	return ioutil.ReadAll(out.Body)
+}
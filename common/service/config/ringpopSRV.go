@@ -0,0 +1,156 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// srvProvider is a discovery provider that resolves dns SRV names into
+// the ip:port of their targets. The targets are ordered by name, or by
+// priority and weight as in RFC 2782 when honoring the priority, which
+// ringpop then follows as its join preference
+type srvProvider struct {
+	names          []string
+	honorPriority  bool
+	resolver       Resolver
+	randomWeighted func(n int) int
+}
+
+func newSRVProvider(names []string, honorPriority bool, resolver Resolver) *srvProvider {
+	return &srvProvider{
+		names:          names,
+		honorPriority:  honorPriority,
+		resolver:       resolverOrDefault(resolver),
+		randomWeighted: rand.Intn,
+	}
+}
+
+// Hosts implements discovery.DiscoverProvider
+func (p *srvProvider) Hosts() ([]string, error) {
+	var result []string
+	for _, name := range p.names {
+		_, records, err := p.resolver.LookupSRV(context.Background(), "", "", name)
+		if err != nil {
+			return nil, newDNSLookupError(name, err)
+		}
+		if p.honorPriority {
+			records = orderSRVByPriority(records, p.randomWeighted)
+		} else {
+			orderSRVByName(records)
+		}
+		for _, record := range records {
+			target := strings.TrimSuffix(record.Target, ".")
+			addrs, err := p.resolver.LookupHost(context.Background(), target)
+			if err != nil {
+				return nil, newDNSLookupError(target, err)
+			}
+			for _, addr := range addrs {
+				result = append(result, net.JoinHostPort(addr, strconv.Itoa(int(record.Port))))
+			}
+		}
+	}
+	return result, nil
+}
+
+func orderSRVByName(records []*net.SRV) {
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Target != records[j].Target {
+			return records[i].Target < records[j].Target
+		}
+		return records[i].Port < records[j].Port
+	})
+}
+
+// orderSRVByPriority orders the records by ascending priority and, within
+// a priority, by a weighted random selection as described in RFC 2782.
+// randomWeighted(n) returns a random number in [0, n)
+func orderSRVByPriority(records []*net.SRV, randomWeighted func(n int) int) []*net.SRV {
+	byPriority := append([]*net.SRV(nil), records...)
+	sort.SliceStable(byPriority, func(i, j int) bool {
+		return byPriority[i].Priority < byPriority[j].Priority
+	})
+	ordered := make([]*net.SRV, 0, len(records))
+	for start := 0; start < len(byPriority); {
+		end := start
+		for end < len(byPriority) && byPriority[end].Priority == byPriority[start].Priority {
+			end++
+		}
+		ordered = append(ordered, selectByWeight(byPriority[start:end], randomWeighted)...)
+		start = end
+	}
+	return ordered
+}
+
+// selectByWeight repeatedly selects a record with a probability
+// proportional to its weight, zero weight records having a small
+// chance of being selected first
+func selectByWeight(records []*net.SRV, randomWeighted func(n int) int) []*net.SRV {
+	remaining := make([]*net.SRV, 0, len(records))
+	// the zero weight records come first in the running sum
+	for _, record := range records {
+		if record.Weight == 0 {
+			remaining = append(remaining, record)
+		}
+	}
+	for _, record := range records {
+		if record.Weight > 0 {
+			remaining = append(remaining, record)
+		}
+	}
+
+	selected := make([]*net.SRV, 0, len(records))
+	for len(remaining) > 0 {
+		total := 0
+		for _, record := range remaining {
+			total += int(record.Weight)
+		}
+		pick := randomWeighted(total + 1)
+		sum := 0
+		for i, record := range remaining {
+			sum += int(record.Weight)
+			if sum >= pick {
+				selected = append(selected, record)
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+func validateSRVNames(names []string) error {
+	if len(names) == 0 {
+		return fmt.Errorf("ringpop config missing boostrap hosts param")
+	}
+	for _, name := range names {
+		if _, _, err := net.SplitHostPort(name); err == nil {
+			return fmt.Errorf("ringpop dnssrv bootstrap host %q must be an SRV name without a port", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	bootstrapFileFormatJSON  = "json"
+	bootstrapFileFormatJSONL = "jsonl"
+)
+
+type (
+	// Seed is a bootstrap host along with optional annotations about it.
+	// Within the bootstrap file, a seed is either a plain "host:port"
+	// string or an object with an address, a zone and a list of tags.
+	Seed struct {
+		Address string   `json:"address"`
+		Zone    string   `json:"zone,omitempty"`
+		Tags    []string `json:"tags,omitempty"`
+	}
+
+	// fileProvider is a discovery provider that reads the seeds
+	// from a bootstrap file, either a json list or json lines
+	fileProvider struct {
+		path   string
+		format string
+	}
+)
+
+// UnmarshalJSON accepts both the plain string and the object form of a seed
+func (seed *Seed) UnmarshalJSON(data []byte) error {
+	var address string
+	if err := json.Unmarshal(data, &address); err == nil {
+		*seed = Seed{Address: address}
+		return nil
+	}
+
+	// an alias type doesn't inherit UnmarshalJSON, avoiding the recursion
+	type annotatedSeed Seed
+	var annotated annotatedSeed
+	if err := json.Unmarshal(data, &annotated); err != nil {
+		return err
+	}
+	if len(annotated.Address) == 0 {
+		return errors.New("seed is missing an address")
+	}
+	*seed = Seed(annotated)
+	return nil
+}
+
+func newFileProvider(path string, format string) *fileProvider {
+	return &fileProvider{path: path, format: strings.ToLower(format)}
+}
+
+// Seeds returns the annotated seeds listed in the bootstrap
+// file, which is decompressed first when gzip compressed
+func (p *fileProvider) Seeds() ([]Seed, error) {
+	data, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read ringpop bootstrap file %v: %v", p.path, err)
+	}
+	return parseBootstrapFile(p.path, p.format, data)
+}
+
+// parseBootstrapFile parses the content of the bootstrap file at path,
+// which is decompressed first when gzip compressed
+func parseBootstrapFile(path string, format string, data []byte) ([]Seed, error) {
+	if isGzip(data) {
+		var err error
+		if data, err = gunzip(data); err != nil {
+			return nil, fmt.Errorf("unable to decompress ringpop bootstrap file %v: %v", path, err)
+		}
+	}
+	seeds, err := seedParser(format)(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse ringpop bootstrap file %v: %v", path, err)
+	}
+	return seeds, nil
+}
+
+// Hosts implements discovery.DiscoverProvider
+func (p *fileProvider) Hosts() ([]string, error) {
+	seeds, err := p.Seeds()
+	if err != nil {
+		return nil, err
+	}
+	return seedAddresses(seeds), nil
+}
+
+// isGzip returns true when data starts with the gzip magic bytes
+func isGzip(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+// seedParser returns the parser of the given bootstrap file format
+func seedParser(format string) func(data []byte) ([]Seed, error) {
+	if strings.ToLower(format) == bootstrapFileFormatJSONL {
+		return parseSeedLines
+	}
+	return parseSeeds
+}
+
+// parseSeeds parses a json list of seeds
+func parseSeeds(data []byte) ([]Seed, error) {
+	var seeds []Seed
+	if err := json.Unmarshal(data, &seeds); err != nil {
+		return nil, err
+	}
+	return seeds, nil
+}
+
+// parseSeedLines parses json lines, one seed per line. Blank lines are skipped
+func parseSeedLines(data []byte) ([]Seed, error) {
+	var seeds []Seed
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var seed Seed
+		if err := json.Unmarshal(text, &seed); err != nil {
+			return nil, fmt.Errorf("line %v: %v", line, err)
+		}
+		seeds = append(seeds, seed)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return seeds, nil
+}
+
+func validateBootstrapFileFormat(format string) error {
+	switch strings.ToLower(format) {
+	case "", bootstrapFileFormatJSON, bootstrapFileFormatJSONL:
+		return nil
+	}
+	return fmt.Errorf("ringpop config `bootstrapFileFormat` must be one of %v or %v, got %q", bootstrapFileFormatJSON, bootstrapFileFormatJSONL, format)
+}
+
+// seedAddresses returns the address of each seed
+func seedAddresses(seeds []Seed) []string {
+	hosts := make([]string, 0, len(seeds))
+	for _, seed := range seeds {
+		hosts = append(hosts, seed.Address)
+	}
+	return hosts
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/uber-common/bark"
+	"github.com/uber-go/tally"
+	"github.com/uber/cadence/common/logging"
+	"github.com/uber/ringpop-go"
+	"github.com/uber/ringpop-go/discovery"
+)
+
+// snapshotSource is the bootstrap fallback metric source of the seed snapshot
+const snapshotSource = "snapshot"
+
+// snapshotFallbackProvider falls back to the seed snapshot file when
+// the primary provider fails or returns fewer than minHosts hosts. A
+// missing or corrupt snapshot is ignored in favor of the primary result
+type snapshotFallbackProvider struct {
+	primary  discovery.DiscoverProvider
+	path     string
+	minHosts int
+	logger   bark.Logger
+	scope    tally.Scope
+}
+
+// Hosts implements discovery.DiscoverProvider
+func (p *snapshotFallbackProvider) Hosts() ([]string, error) {
+	hosts, err := p.primary.Hosts()
+	if err == nil && len(hosts) >= p.minHosts {
+		return hosts, nil
+	}
+	snapshot, snapshotErr := newFileProvider(p.path, bootstrapFileFormatJSON).Hosts()
+	if snapshotErr != nil || len(snapshot) == 0 {
+		return hosts, err
+	}
+	p.logger.WithFields(bark.Fields{
+		logging.TagErr: err,
+		"hosts":        len(hosts),
+		"snapshot":     p.path,
+		"snapshotSize": len(snapshot),
+	}).Warn("Ringpop discovery returned too few hosts, bootstrapping from the seed snapshot")
+	p.scope.Tagged(map[string]string{bootstrapSourceTag: snapshotSource}).Counter(bootstrapFallbackMetric).Inc(1)
+	return snapshot, nil
+}
+
+// withSnapshotFallback wraps the provider with the
+// seed snapshot fallback, when a snapshot file is set
+func (factory *RingpopFactory) withSnapshotFallback(provider discovery.DiscoverProvider) discovery.DiscoverProvider {
+	if len(factory.config.SeedSnapshotFile) == 0 {
+		return provider
+	}
+	return &snapshotFallbackProvider{
+		primary:  provider,
+		path:     factory.config.SeedSnapshotFile,
+		minHosts: factory.config.SeedSnapshotMinHosts,
+		logger:   factory.bootstrapLogger(),
+		scope:    factory.metricsScope,
+	}
+}
+
+// startSeedSnapshots writes the members of the ring to the seed
+// snapshot file right away and then periodically, if a file is set
+func (factory *RingpopFactory) startSeedSnapshots(rp *ringpop.Ringpop) {
+	if len(factory.config.SeedSnapshotFile) == 0 {
+		return
+	}
+	factory.shutdownWG.Add(1)
+	go factory.seedSnapshotLoop(rp)
+}
+
+func (factory *RingpopFactory) seedSnapshotLoop(rp *ringpop.Ringpop) {
+	defer factory.shutdownWG.Done()
+
+	getMembers := func() ([]string, error) {
+		return rp.GetReachableMembers()
+	}
+	for {
+		if err := factory.writeSeedSnapshot(getMembers); err != nil {
+			factory.logger.WithFields(bark.Fields{
+				logging.TagErr: err,
+				"snapshot":     factory.config.SeedSnapshotFile,
+			}).Warn("Ringpop failed to write the seed snapshot")
+		}
+		select {
+		case <-factory.shutdownCh:
+			return
+		case <-factory.clock.After(factory.config.SeedSnapshotInterval):
+		}
+	}
+}
+
+// writeSeedSnapshot writes the members to the snapshot file as a json
+// list. The file is replaced atomically, so that a crash never leaves
+// a partial snapshot behind
+func (factory *RingpopFactory) writeSeedSnapshot(getMembers func() ([]string, error)) error {
+	members, err := getMembers()
+	if err != nil || len(members) == 0 {
+		return err
+	}
+	data, err := json.Marshal(members)
+	if err != nil {
+		return err
+	}
+	path := factory.config.SeedSnapshotFile
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
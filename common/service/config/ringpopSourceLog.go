@@ -0,0 +1,121 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/uber-common/bark"
+	"github.com/uber/cadence/common/logging"
+	"github.com/uber/ringpop-go/discovery"
+)
+
+type (
+	// sourceOutcome is the result of querying a single discovery source
+	sourceOutcome struct {
+		source   string
+		hosts    int
+		duration time.Duration
+		err      error
+	}
+
+	// outcomeProvider wraps a discovery source and
+	// reports the outcome of each of its queries
+	outcomeProvider struct {
+		name     string
+		provider discovery.DiscoverProvider
+		clock    Clock
+		report   func(sourceOutcome)
+	}
+)
+
+// Hosts implements discovery.DiscoverProvider
+func (p *outcomeProvider) Hosts() ([]string, error) {
+	start := p.clock.Now()
+	hosts, err := p.provider.Hosts()
+	p.report(sourceOutcome{
+		source:   p.name,
+		hosts:    len(hosts),
+		duration: p.clock.Now().Sub(start),
+		err:      err,
+	})
+	return hosts, err
+}
+
+// withSourceOutcomes wraps the discovery sources of the given provider,
+// or the provider itself when it has a single source, so that the outcome
+// of each of them is logged. Entries of the same discovery attempt share
+// a discovery attempt number, along with the bootstrap attempt id
+func (factory *RingpopFactory) withSourceOutcomes(provider discovery.DiscoverProvider) discovery.DiscoverProvider {
+	logger := factory.bootstrapLogger().WithField("discoveryAttempt", atomic.AddUint64(&factory.discoveryAttempts, 1))
+	report := func(outcome sourceOutcome) {
+		logSourceOutcome(logger, outcome)
+	}
+
+	if composite, ok := provider.(*compositeProvider); ok {
+		for i, src := range composite.sources {
+			composite.sources[i].provider = &outcomeProvider{
+				name:     src.name,
+				provider: src.provider,
+				clock:    factory.clock,
+				report:   report,
+			}
+		}
+		return composite
+	}
+	if blend, ok := provider.(*blendProvider); ok {
+		for _, src := range []*namedProvider{&blend.file, &blend.dynamic} {
+			src.provider = &outcomeProvider{
+				name:     src.name,
+				provider: src.provider,
+				clock:    factory.clock,
+				report:   report,
+			}
+		}
+		return blend
+	}
+
+	name := factory.bootstrapMode.String()
+	if factory.config.DiscoveryProvider != nil {
+		name = BootstrapModeCustom.String()
+	}
+	return &outcomeProvider{
+		name:     name,
+		provider: provider,
+		clock:    factory.clock,
+		report:   report,
+	}
+}
+
+func logSourceOutcome(logger bark.Logger, outcome sourceOutcome) {
+	fields := bark.Fields{
+		"source":   outcome.source,
+		"hosts":    outcome.hosts,
+		"duration": outcome.duration,
+	}
+	if outcome.err != nil {
+		fields[logging.TagErr] = outcome.err
+		logger.WithFields(fields).Warn("Ringpop discovery source failed")
+		return
+	}
+	logger.WithFields(fields).Info("Ringpop discovery source resolved")
+}
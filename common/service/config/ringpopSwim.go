@@ -0,0 +1,170 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/uber/ringpop-go"
+	"github.com/uber/ringpop-go/swim"
+	tcg "github.com/uber/tchannel-go"
+	"github.com/uber/tchannel-go/json"
+)
+
+// swimPingEndpoint is where every ringpop member answers the swim pings
+const swimPingEndpoint = "/protocol/ping"
+
+type (
+	// swimCaller makes a json call to the ringpop service of the member at
+	// hostPort, which is abandoned when the context is done
+	swimCaller func(ctx context.Context, hostPort string, method string, arg interface{}, resp interface{}) error
+
+	// swimPing is the body of a swim ping and of its response, as
+	// exchanged by the gossip of ringpop
+	swimPing struct {
+		Changes           []swimChange `json:"changes"`
+		Checksum          uint32       `json:"checksum"`
+		Source            string       `json:"source"`
+		SourceIncarnation int64        `json:"sourceIncarnationNumber"`
+		App               string       `json:"app"`
+	}
+
+	// swimChange is a membership record disseminated by a swim ping
+	swimChange struct {
+		Source            string            `json:"source"`
+		SourceIncarnation int64             `json:"sourceIncarnationNumber"`
+		Address           string            `json:"address"`
+		Incarnation       int64             `json:"incarnationNumber"`
+		Status            string            `json:"status"`
+		Labels            map[string]string `json:"labels,omitempty"`
+		Timestamp         int64             `json:"timestamp"`
+	}
+)
+
+// swimCaller returns the caller of the ringpop service of the members,
+// which goes over the given channel unless the factory has one set
+func (factory *RingpopFactory) swimCaller(ch *tcg.Channel) swimCaller {
+	if factory.swimCall != nil {
+		return factory.swimCall
+	}
+	timeout := factory.joinTimeout()
+	return func(ctx context.Context, hostPort string, method string, arg interface{}, resp interface{}) error {
+		callCtx, cancel := swimCallContext(ctx, timeout)
+		defer cancel()
+		client := json.NewClient(ch, ringpopServiceName, &json.ClientOptions{HostPort: hostPort})
+		return client.Call(callCtx, method, arg, resp)
+	}
+}
+
+// swimCallContext returns the context of a swim call, which times out
+// after timeout or at the deadline of ctx, whichever comes first, and
+// is canceled along with ctx
+func swimCallContext(ctx context.Context, timeout time.Duration) (tcg.ContextWithHeaders, context.CancelFunc) {
+	return tcg.NewContextBuilder(timeout).SetParentContext(ctx).Build()
+}
+
+// selfPing returns a swim ping carrying the membership record of this
+// node, read from its own admin stats, along with the members it knows
+func (factory *RingpopFactory) selfPing(ctx context.Context, call swimCaller, self string) (*swimPing, *peerStats, error) {
+	var stats peerStats
+	if err := call(ctx, self, ringpopAdminStatsEndpoint, nil, &stats); err != nil {
+		return nil, nil, fmt.Errorf("unable to read the membership of this node: %v", err)
+	}
+	record, ok := stats.member(self)
+	if !ok {
+		return nil, nil, fmt.Errorf("this node %v is missing from its own membership", self)
+	}
+	return &swimPing{
+		Changes: []swimChange{{
+			Source:            self,
+			SourceIncarnation: record.Incarnation,
+			Address:           self,
+			Incarnation:       record.Incarnation,
+			Status:            swim.Alive,
+			Labels:            record.Labels,
+			Timestamp:         factory.clock.Now().UnixNano() / int64(time.Millisecond),
+		}},
+		Checksum:          stats.Membership.Checksum,
+		Source:            self,
+		SourceIncarnation: record.Incarnation,
+		App:               factory.ringAppName(),
+	}, &stats, nil
+}
+
+// nudgeSeeds makes the seeds that are not members of the ring learn about
+// this node, by sending each of them a swim ping carrying the membership
+// record of this node, like its gossip does. A seed that learns about this
+// node gossips with it, which merges the views of the ring. Unlike joining
+// again, it leaves ringpop untouched when seeds are unreachable
+func (factory *RingpopFactory) nudgeSeeds(ctx context.Context, ch *tcg.Channel, rp *ringpop.Ringpop, seeds []string) error {
+	if !rp.Ready() {
+		return ErrRingpopNotBootstrapped
+	}
+	self, err := rp.WhoAmI()
+	if err != nil {
+		return err
+	}
+	return factory.pingSeeds(ctx, factory.swimCaller(ch), self, seeds)
+}
+
+// pingSeeds sends the swim ping of this node to the seeds that are not
+// members, it returns an error listing the seeds that couldn't be reached
+func (factory *RingpopFactory) pingSeeds(ctx context.Context, call swimCaller, self string, seeds []string) error {
+	ping, stats, err := factory.selfPing(ctx, call, self)
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, seed := range dedupHosts(seeds) {
+		if membershipKey(seed) == membershipKey(ping.Source) || stats.isMember(seed) {
+			continue
+		}
+		var resp swimPing
+		if err := call(ctx, seed, swimPingEndpoint, ping, &resp); err != nil {
+			failed = append(failed, fmt.Sprintf("%v (%v)", seed, err))
+		}
+	}
+	if len(failed) > 0 {
+		return errors.New("unreachable seeds " + strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// member returns the membership record of the member at address
+func (stats *peerStats) member(address string) (peerMember, bool) {
+	key := membershipKey(address)
+	for _, member := range stats.Membership.Members {
+		if membershipKey(member.Address) == key {
+			return member, true
+		}
+	}
+	return peerMember{}, false
+}
+
+// isMember tells whether address is an alive or suspect member
+func (stats *peerStats) isMember(address string) bool {
+	member, ok := stats.member(address)
+	return ok && (member.Status == swim.Alive || member.Status == swim.Suspect)
+}
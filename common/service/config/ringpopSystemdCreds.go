@@ -0,0 +1,72 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// credentialsDirectoryEnv is the environment variable systemd sets
+// to the directory holding the credentials of the unit
+const credentialsDirectoryEnv = "CREDENTIALS_DIRECTORY"
+
+// ErrCredentialsDirectoryNotSet is returned when the systemd credentials
+// bootstrap mode is used outside of a unit with credentials
+var ErrCredentialsDirectoryNotSet = errors.New("ringpop systemd credentials bootstrap requires $" + credentialsDirectoryEnv)
+
+// systemdCredsProvider is a discovery provider that reads the seeds from a
+// systemd credential, parsed like a bootstrap file of the given format
+type systemdCredsProvider struct {
+	name   string
+	format string
+	getenv func(key string) string
+}
+
+func newSystemdCredsProvider(name string, format string) *systemdCredsProvider {
+	return &systemdCredsProvider{name: name, format: format, getenv: os.Getenv}
+}
+
+// Hosts implements discovery.DiscoverProvider
+func (p *systemdCredsProvider) Hosts() ([]string, error) {
+	dir := p.getenv(credentialsDirectoryEnv)
+	if len(dir) == 0 {
+		return nil, ErrCredentialsDirectoryNotSet
+	}
+	path := filepath.Join(dir, p.name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, fmt.Errorf("ringpop bootstrap credential %q is missing from %v", p.name, dir)
+	}
+	return newFileProvider(path, p.format).Hosts()
+}
+
+func validateSystemdCredential(name string) error {
+	if len(name) == 0 {
+		return fmt.Errorf("ringpop config missing bootstrap systemd credential param")
+	}
+	if strings.ContainsRune(name, '/') || name == "." || name == ".." {
+		return fmt.Errorf("ringpop config `bootstrapSystemdCredential` %q must be a credential name, not a path", name)
+	}
+	return nil
+}
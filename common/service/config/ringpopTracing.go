@@ -0,0 +1,98 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"context"
+	"time"
+
+	"github.com/uber/ringpop-go"
+	tcg "github.com/uber/tchannel-go"
+)
+
+const (
+	spanBootstrap = "ringpop.bootstrap"
+	spanDiscovery = "ringpop.discovery"
+	spanJoin      = "ringpop.join"
+)
+
+type (
+	// Tracer starts the spans of the bootstrap phases, it adapts a tracing
+	// library, like OpenTelemetry, without the factory depending on it
+	Tracer interface {
+		// StartSpan starts a span, a child of the span of the context when
+		// there is one, and returns a context holding the new span
+		StartSpan(ctx context.Context, name string) (context.Context, Span)
+	}
+
+	// Span is a bootstrap phase being traced
+	Span interface {
+		// SetAttribute records an attribute of the span
+		SetAttribute(key string, value interface{})
+		// End ends the span, with the error of the phase if it failed
+		End(err error)
+	}
+
+	noopTracer struct{}
+	noopSpan   struct{}
+)
+
+// WithTracer sets the tracer of the bootstrap phases, the discovery of
+// the seeds and the join of the ring, which are traced under a bootstrap
+// span for each CreateRingpop. Nothing is traced by default
+func WithTracer(tracer Tracer) FactoryOption {
+	return func(factory *RingpopFactory) {
+		factory.tracer = tracer
+	}
+}
+
+// StartSpan implements Tracer
+func (noopTracer) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+// SetAttribute implements Span
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+
+// End implements Span
+func (noopSpan) End(err error) {}
+
+// tracedResolveAndPrepare resolves the seeds under a discovery span
+func (factory *RingpopFactory) tracedResolveAndPrepare(ctx context.Context, ch *tcg.Channel, maxJoinDuration time.Duration, attempt int) (*PreparedBootstrap, error) {
+	ctx, span := factory.tracer.StartSpan(ctx, spanDiscovery)
+	span.SetAttribute("ringpop.attempt", attempt)
+	prepared, err := factory.resolveAndPrepare(ctx, ch, maxJoinDuration)
+	if err == nil {
+		span.SetAttribute("ringpop.seeds", len(prepared.hosts))
+	}
+	span.End(err)
+	return prepared, err
+}
+
+// tracedJoin joins the ring under a join span
+func (factory *RingpopFactory) tracedJoin(ctx context.Context, prepared *PreparedBootstrap, attempt int) (*ringpop.Ringpop, error) {
+	_, span := factory.tracer.StartSpan(ctx, spanJoin)
+	span.SetAttribute("ringpop.attempt", attempt)
+	span.SetAttribute("ringpop.seeds", len(prepared.hosts))
+	rp, err := prepared.Join()
+	span.End(err)
+	return rp, err
+}
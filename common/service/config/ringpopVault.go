@@ -0,0 +1,150 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// vaultTokenEnv is the environment variable holding the vault token. Without
+// it, requests go out unauthenticated, as expected by a vault agent that
+// adds its auto auth token
+const vaultTokenEnv = "VAULT_TOKEN"
+
+type (
+	// vaultProvider is a discovery provider reading the seeds from a field
+	// of a vault kv secret, parsed like a bootstrap file of the given format
+	vaultProvider struct {
+		address string
+		path    string
+		field   string
+		format  string
+		token   string
+		client  *http.Client
+	}
+
+	// vaultResponse is the response of a kv read. The secret is under data
+	// with the kv version 1 engine, and under data.data with version 2
+	vaultResponse struct {
+		Data   map[string]json.RawMessage `json:"data"`
+		Errors []string                   `json:"errors"`
+	}
+)
+
+func newVaultProvider(address string, path string, field string, format string, timeout time.Duration) *vaultProvider {
+	return &vaultProvider{
+		address: strings.TrimSuffix(address, "/"),
+		path:    strings.Trim(path, "/"),
+		field:   field,
+		format:  format,
+		token:   os.Getenv(vaultTokenEnv),
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Hosts implements discovery.DiscoverProvider
+func (p *vaultProvider) Hosts() ([]string, error) {
+	secret, err := p.read()
+	if err != nil {
+		return nil, fmt.Errorf("ringpop vault discovery for %v: %v", p.path, err)
+	}
+	value, ok := secret[p.field]
+	if !ok {
+		return nil, fmt.Errorf("ringpop vault discovery for %v: secret has no field %q", p.path, p.field)
+	}
+	// the field is either a string holding the seed list or the seed list itself
+	var data []byte
+	var text string
+	if err := json.Unmarshal(value, &text); err == nil {
+		data = []byte(text)
+	} else {
+		data = value
+	}
+	seeds, err := seedParser(p.format)(data)
+	if err != nil {
+		return nil, fmt.Errorf("ringpop vault discovery for %v: unable to parse field %q: %v", p.path, p.field, err)
+	}
+	return seedAddresses(seeds), nil
+}
+
+// read returns the fields of the secret, with either kv engine version
+func (p *vaultProvider) read() (map[string]json.RawMessage, error) {
+	u := p.address + "/v1/" + (&url.URL{Path: p.path}).EscapedPath()
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.token) > 0 {
+		req.Header.Set("X-Vault-Token", p.token)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var result vaultResponse
+	if resp.StatusCode != http.StatusOK {
+		if json.Unmarshal(body, &result) == nil && len(result.Errors) > 0 {
+			return nil, fmt.Errorf("GET returned %v: %v", resp.Status, strings.Join(result.Errors, ", "))
+		}
+		return nil, fmt.Errorf("GET returned %v", resp.Status)
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	if nested, ok := result.Data["data"]; ok {
+		if _, ok := result.Data["metadata"]; ok {
+			var secret map[string]json.RawMessage
+			if err := json.Unmarshal(nested, &secret); err != nil {
+				return nil, err
+			}
+			return secret, nil
+		}
+	}
+	return result.Data, nil
+}
+
+func validateVaultConfig(address string, path string, field string) error {
+	if len(address) == 0 {
+		return fmt.Errorf("ringpop config missing bootstrap vault address param")
+	}
+	if u, err := url.Parse(address); err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return fmt.Errorf("ringpop config `bootstrapVaultAddress` %q must be an http or https url", address)
+	}
+	if len(strings.Trim(path, "/")) == 0 {
+		return fmt.Errorf("ringpop config missing bootstrap vault path param")
+	}
+	if len(field) == 0 {
+		return fmt.Errorf("ringpop config missing bootstrap vault field param")
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"context"
+	"time"
+
+	"github.com/uber-common/bark"
+	"github.com/uber/cadence/common/logging"
+)
+
+// bootstrapWarmupPollInterval is how often the member
+// count is sampled while warming up
+const bootstrapWarmupPollInterval = 500 * time.Millisecond
+
+// settleDiscovery waits, for at most the discovery initial delay, until
+// two polls in a row of the discovery provider return the same non zero
+// number of seeds, so that the first bootstrap doesn't join a partial
+// set of peers that are still registering. Failed polls count as no seed
+func (factory *RingpopFactory) settleDiscovery(ctx context.Context) error {
+	delay := factory.config.DiscoveryInitialDelay
+	if delay <= 0 {
+		return nil
+	}
+	provider, err := factory.discoveryProvider()
+	if err != nil {
+		return err
+	}
+	pollInterval := bootstrapWarmupPollInterval
+	if delay < pollInterval {
+		pollInterval = delay
+	}
+	countSeeds := func() int {
+		hosts, err := hostsWithContext(ctx, provider)
+		if err != nil {
+			return 0
+		}
+		return len(hosts)
+	}
+
+	start := factory.clock.Now()
+	deadline := start.Add(delay)
+	count := countSeeds()
+	settled := false
+	for !settled && factory.clock.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-factory.clock.After(pollInterval):
+		}
+		current := countSeeds()
+		settled = current > 0 && current == count
+		count = current
+	}
+
+	logger := factory.bootstrapLogger().WithFields(bark.Fields{
+		"seeds":   count,
+		"elapsed": factory.clock.Now().Sub(start),
+	})
+	if settled {
+		logger.Info("Ringpop discovery settled")
+	} else {
+		logger.Warn("Ringpop discovery initial delay elapsed before the seeds settled")
+	}
+	return nil
+}
+
+// warmup waits after a successful join until the member count stops
+// growing for the bootstrap warmup, or the max warmup elapses, so that
+// the first routing decisions are made against a settled ring
+func (factory *RingpopFactory) warmup(ctx context.Context, countMembers func() (int, error)) {
+	window := factory.config.BootstrapWarmup
+	if window <= 0 {
+		return
+	}
+	pollInterval := bootstrapWarmupPollInterval
+	if window < pollInterval {
+		pollInterval = window
+	}
+
+	start := factory.clock.Now()
+	deadline := start.Add(factory.config.BootstrapWarmupMax)
+	lastGrowth := start
+	count, err := countMembers()
+	for err == nil {
+		now := factory.clock.Now()
+		if now.Sub(lastGrowth) >= window || !now.Before(deadline) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			continue
+		case <-factory.clock.After(pollInterval):
+		}
+		var current int
+		if current, err = countMembers(); err == nil && current > count {
+			count, lastGrowth = current, factory.clock.Now()
+		}
+	}
+
+	logger := factory.bootstrapLogger().WithFields(bark.Fields{
+		"members": count,
+		"elapsed": factory.clock.Now().Sub(start),
+	})
+	if err != nil {
+		logger.WithFields(bark.Fields{logging.TagErr: err}).Warn("Ringpop warmup interrupted")
+		return
+	}
+	logger.Info("Ringpop warmup settled")
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/samuel/go-zookeeper/zk"
+)
+
+type (
+	// zkNode is the content of a znode
+	zkNode struct {
+		data     []byte
+		children []string
+	}
+
+	// zkNodeFetcher returns the content of a znode
+	zkNodeFetcher func(servers []string, path string, timeout time.Duration) (*zkNode, error)
+
+	// zkProvider is a discovery provider that reads the seeds from a
+	// zookeeper znode, either from its children, named after the host:port
+	// of the seeds, or from its data, which has the bootstrap file format
+	zkProvider struct {
+		servers  []string
+		path     string
+		readData bool
+		format   string
+		timeout  time.Duration
+		fetch    zkNodeFetcher
+	}
+)
+
+func newZKProvider(servers []string, path string, readData bool, format string, timeout time.Duration) *zkProvider {
+	return &zkProvider{
+		servers:  servers,
+		path:     path,
+		readData: readData,
+		format:   format,
+		timeout:  timeout,
+		fetch:    fetchZKNode,
+	}
+}
+
+// zkServers splits a zookeeper connection string into its servers
+func zkServers(connect string) []string {
+	var servers []string
+	for _, server := range strings.Split(connect, ",") {
+		if server = strings.TrimSpace(server); len(server) > 0 {
+			servers = append(servers, server)
+		}
+	}
+	return servers
+}
+
+// Seeds returns the seeds listed in the znode
+func (p *zkProvider) Seeds() ([]Seed, error) {
+	node, err := p.fetch(p.servers, p.path, p.timeout)
+	if err == zk.ErrNoNode {
+		return nil, fmt.Errorf("ringpop bootstrap znode %v does not exist", p.path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read ringpop bootstrap znode %v: %v", p.path, err)
+	}
+	if p.readData {
+		seeds, err := seedParser(p.format)(node.data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse ringpop bootstrap znode %v: %v", p.path, err)
+		}
+		return seeds, nil
+	}
+	seeds := make([]Seed, 0, len(node.children))
+	for _, child := range node.children {
+		if _, _, err := net.SplitHostPort(child); err != nil {
+			return nil, fmt.Errorf("ringpop bootstrap znode %v has child %q that isn't a host:port", p.path, child)
+		}
+		seeds = append(seeds, Seed{Address: child})
+	}
+	return seeds, nil
+}
+
+// Hosts implements discovery.DiscoverProvider
+func (p *zkProvider) Hosts() ([]string, error) {
+	seeds, err := p.Seeds()
+	if err != nil {
+		return nil, err
+	}
+	return seedAddresses(seeds), nil
+}
+
+// fetchZKNode reads a znode over a connection that is closed once done.
+// Requests are queued until the session is established, so closing the
+// connection on timeout is what fails them when zookeeper is unreachable
+func fetchZKNode(servers []string, path string, timeout time.Duration) (*zkNode, error) {
+	conn, _, err := zk.Connect(servers, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	type result struct {
+		node *zkNode
+		err  error
+	}
+	// buffered so that an abandoned read never blocks on send
+	resultC := make(chan result, 1)
+	go func() {
+		data, _, err := conn.Get(path)
+		if err != nil {
+			resultC <- result{err: err}
+			return
+		}
+		children, _, err := conn.Children(path)
+		resultC <- result{node: &zkNode{data: data, children: children}, err: err}
+	}()
+
+	var timeoutC <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutC = timer.C
+	}
+	select {
+	case r := <-resultC:
+		return r.node, r.err
+	case <-timeoutC:
+		return nil, fmt.Errorf("timed out after %v", timeout)
+	}
+}
@@ -21,18 +21,90 @@
 package config
 
 import (
+	"compress/gzip"
+	"context"
+	"errors"
 	"fmt"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"github.com/uber-common/bark"
+	"github.com/uber-go/tally"
+	"github.com/uber/ringpop-go"
 	"github.com/uber/ringpop-go/discovery/statichosts"
+	"github.com/uber/ringpop-go/events"
+	"github.com/uber/ringpop-go/swim"
+	tcg "github.com/uber/tchannel-go"
 	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"testing/quick"
 	"time"
 )
 
-type RingpopSuite struct {
-	*require.Assertions
-	suite.Suite
+type (
+	RingpopSuite struct {
+		*require.Assertions
+		suite.Suite
+	}
+
+	// fakeClock guards now since the background loops of the
+	// factory read it while the tests move it forward
+	fakeClock struct {
+		mutex  sync.Mutex
+		now    time.Time
+		afterC chan time.Time
+	}
+)
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(0, 0), afterC: make(chan time.Time)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// advance moves the clock forward by d
+func (c *fakeClock) advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	return c.afterC
+}
+
+// firingClock is a clock whose timers fire right away
+type firingClock struct {
+	fakeClock
+	waited []time.Duration
+}
+
+func (c *firingClock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.waited = append(c.waited, d)
+	c.now = c.now.Add(d)
+	firedC := make(chan time.Time, 1)
+	firedC <- c.now
+	return firedC
+}
+
+// waits returns the durations waited on the clock so far
+func (c *firingClock) waits() []time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]time.Duration(nil), c.waited...)
 }
 
 func TestRingpopSuite(t *testing.T) {
@@ -99,6 +171,83 @@ func (s *RingpopSuite) TestInvalidConfig() {
 	s.NotNil(err)
 }
 
+func (s *RingpopSuite) TestValidateAll() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	s.Empty(cfg.ValidateAll())
+
+	cfg.Name = ""
+	cfg.LeaveGracePeriod = -time.Second
+	cfg.BootstrapHostDenyList = []string{"10.0.0.1", "10.0.0.2"}
+	errs := cfg.ValidateAll()
+	s.Len(errs, 4)
+	s.Equal("ringpop config missing `name` param", errs[0].Error())
+	s.Contains(errs[1].Error(), "`leaveGracePeriod`")
+	s.Contains(errs[2].Error(), `"10.0.0.1"`)
+	s.Contains(errs[3].Error(), `"10.0.0.2"`)
+	s.Equal(errs[0], cfg.validate())
+}
+
+func (s *RingpopSuite) TestAppName() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	s.Equal("", cfg.AppName)
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+	s.Equal("test", f.config.AppName)
+
+	cfg.AppName = "cadence-app"
+	f, err = cfg.NewFactory()
+	s.Nil(err)
+	s.Equal("cadence-app", f.config.AppName)
+	s.Equal("test", f.config.Name)
+
+	cfg.AppName = "cadence app"
+	s.NotNil(cfg.validate())
+
+	// the charset only applies to name when strict, names that were valid stay valid
+	cfg.AppName = ""
+	cfg.Name = "test/ring"
+	s.Nil(cfg.validate())
+	cfg.NameCasing = "strict"
+	s.NotNil(cfg.validate())
+}
+
+func (s *RingpopSuite) TestCompositeMode() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getCompositeConfig()), &cfg)
+	s.Nil(err)
+	s.Equal(BootstrapModeComposite, cfg.BootstrapMode)
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+	s.Equal(1, f.config.CompositeMinSuccessfulSources)
+
+	provider, err := f.discoveryProvider()
+	s.Nil(err)
+	hosts, err := provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"127.0.0.1:1111", "127.0.0.1:2222"}, hosts)
+
+	cfg.CompositeMinSuccessfulSources = 2
+	f, err = cfg.NewFactory()
+	s.Nil(err)
+	provider, err = f.discoveryProvider()
+	s.Nil(err)
+	_, err = provider.Hosts()
+	s.NotNil(err)
+	s.Contains(err.Error(), "1 of 2 sources succeeded")
+	s.Contains(err.Error(), "file:")
+
+	cfg.CompositeMinSuccessfulSources = 3
+	s.NotNil(cfg.validate())
+	cfg.BootstrapHosts = nil
+	cfg.BootstrapFile = ""
+	cfg.CompositeMinSuccessfulSources = 0
+	s.NotNil(cfg.validate())
+}
+
 func getJSONConfig() string {
 	return `name: "test"
 bootstrapMode: "file"
@@ -118,3 +267,2143 @@ func getCustomConfig() string {
 bootstrapMode: "custom"
 maxJoinDuration: 30s`
 }
+
+func getCompositeConfig() string {
+	return `name: "test"
+bootstrapMode: "composite"
+bootstrapHosts: ["127.0.0.1:1111", "127.0.0.1:2222", "127.0.0.1:1111"]
+bootstrapFile: "/tmp/does-not-exist.json"
+maxJoinDuration: 30s`
+}
+
+func (s *RingpopSuite) TestSelfTestBeforeCreate() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+	s.Equal(ErrRingpopNotCreated, f.SelfTest(context.Background()))
+	s.Equal(ErrRingpopNotCreated, f.Healthy())
+	s.Equal(ErrRingpopNotCreated, f.AddSeeds([]string{"10.0.0.1:7933"}))
+	s.Equal(ErrRingpopNotCreated, f.WaitForMembership(context.Background(), func([]string) bool { return true }))
+}
+
+func (s *RingpopSuite) TestSelfTestStages() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+
+	ch, err := tcg.NewChannel("cadence-test", nil)
+	s.Nil(err)
+	defer ch.Close()
+	rp, err := ringpop.New("test", ringpop.Channel(ch))
+	s.Nil(err)
+	defer rp.Destroy()
+	f.mutex.Lock()
+	f.channel, f.ringpop = ch, rp
+	f.mutex.Unlock()
+
+	s.Equal(ErrRingpopChannelNotListening, f.SelfTest(context.Background()))
+	s.Nil(ch.ListenAndServe("127.0.0.1:0"))
+	s.Equal(ErrRingpopNotBootstrapped, f.SelfTest(context.Background()))
+	s.Equal(ErrRingpopNotBootstrapped, f.Healthy())
+}
+
+func (s *RingpopSuite) TestSelfTestPing() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+	ctx := context.Background()
+
+	// being the only member only passes on the first node
+	ring := newFakeSwim("10.0.0.1:7933")
+	s.Equal(ErrRingpopSelfOnly, f.pingPeers(ctx, ring.call, "10.0.0.1:7933", ring.members))
+	cfg.FirstNode = true
+	s.Nil(f.pingPeers(ctx, ring.call, "10.0.0.1:7933", ring.members))
+	s.Empty(ring.pinged)
+	cfg.FirstNode = false
+
+	// the peers are pinged until one answers
+	ring = newFakeSwim("10.0.0.1:7933", "10.0.0.2:7933", "10.0.0.3:7933")
+	ring.down["10.0.0.2:7933"] = true
+	s.Nil(f.pingPeers(ctx, ring.call, "10.0.0.1:7933", ring.members))
+	s.Equal([]string{"10.0.0.3:7933"}, ring.pinged)
+	s.Equal("10.0.0.1:7933", ring.pings[0].Source)
+
+	ring.down["10.0.0.3:7933"] = true
+	s.Equal(ErrRingpopPingFailed, f.pingPeers(ctx, ring.call, "10.0.0.1:7933", ring.members))
+
+	// nothing is pinged once the context is done
+	ring = newFakeSwim("10.0.0.1:7933", "10.0.0.2:7933")
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	s.NotNil(f.pingPeers(canceled, ring.call, "10.0.0.1:7933", ring.members))
+	s.Empty(ring.pinged)
+
+	// the ping carries the record of self, which must be readable
+	ring.down["10.0.0.1:7933"] = true
+	err = f.pingPeers(ctx, ring.call, "10.0.0.1:7933", ring.members)
+	s.NotNil(err)
+	s.NotEqual(ErrRingpopPingFailed, err)
+	s.Empty(ring.pinged)
+}
+
+func (s *RingpopSuite) TestSwimCallContext() {
+	// the call times out at the deadline of the parent when it comes first
+	parent, cancel := context.WithTimeout(context.Background(), time.Second)
+	callCtx, callCancel := swimCallContext(parent, time.Minute)
+	defer callCancel()
+	parentDeadline, _ := parent.Deadline()
+	deadline, ok := callCtx.Deadline()
+	s.True(ok)
+	s.False(deadline.After(parentDeadline))
+
+	// and is canceled along with the parent
+	cancel()
+	<-callCtx.Done()
+	s.NotNil(callCtx.Err())
+
+	// otherwise it times out after the timeout
+	callCtx, callCancel = swimCallContext(context.Background(), time.Minute)
+	defer callCancel()
+	deadline, ok = callCtx.Deadline()
+	s.True(ok)
+	s.True(deadline.After(time.Now().Add(30 * time.Second)))
+}
+
+func (s *RingpopSuite) TestPingSeeds() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+
+	// a live ring of self and 10.0.0.2, where 10.0.0.4 is unreachable
+	ring := newFakeSwim("10.0.0.1:7933", "10.0.0.2:7933")
+	ring.down["10.0.0.4:7933"] = true
+	err = f.pingSeeds(context.Background(), ring.call, "10.0.0.1:7933", []string{"10.0.0.1:7933", "10.0.0.2:7933", "10.0.0.3:7933", "10.0.0.4:7933", "10.0.0.3:7933"})
+	s.NotNil(err)
+	s.Contains(err.Error(), "10.0.0.4:7933")
+	s.NotContains(err.Error(), "10.0.0.3:7933")
+
+	// only the seeds that aren't members are pinged, with the record of self
+	s.Equal([]string{"10.0.0.3:7933"}, ring.pinged)
+	s.Equal("10.0.0.1:7933", ring.pings[0].Source)
+	s.Equal(int64(42), ring.pings[0].SourceIncarnation)
+	s.Equal("test", ring.pings[0].App)
+	s.Equal([]swimChange{{
+		Source:            "10.0.0.1:7933",
+		SourceIncarnation: 42,
+		Address:           "10.0.0.1:7933",
+		Incarnation:       42,
+		Status:            swim.Alive,
+		Labels:            map[string]string{serviceNameLabel: "cadence-history"},
+		Timestamp:         ring.pings[0].Changes[0].Timestamp,
+	}}, ring.pings[0].Changes)
+
+	// the failed ping leaves the live ring as it was
+	s.Equal([]string{"10.0.0.1:7933", "10.0.0.2:7933"}, ring.members)
+
+	ring.down["10.0.0.1:7933"] = true
+	s.NotNil(f.pingSeeds(context.Background(), ring.call, "10.0.0.1:7933", []string{"10.0.0.3:7933"}))
+}
+
+func (s *RingpopSuite) TestResolveAndPrepare() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+	ch, err := tcg.NewChannel("test", nil)
+	s.Nil(err)
+	defer ch.Close()
+	s.Nil(ch.ListenAndServe("127.0.0.1:0"))
+	prepared, err := f.ResolveAndPrepare(ch)
+	s.Nil(err)
+	s.Equal([]string{"127.0.0.1:1111"}, prepared.Hosts())
+	s.Empty(prepared.address)
+	s.Equal(cfg.MaxJoinDuration, prepared.maxJoinDuration)
+
+	prepared, err = f.resolveAndPrepare(context.Background(), ch, 2*time.Second)
+	s.Nil(err)
+	s.Equal(2*time.Second, prepared.maxJoinDuration)
+	s.NotEqual(2*time.Second, cfg.MaxJoinDuration)
+	_, err = f.CreateRingpopWithJoinDuration(nil, -time.Second)
+	s.NotNil(err)
+
+	err = yaml.Unmarshal([]byte(getJSONConfig()), &cfg)
+	s.Nil(err)
+	cfg.BootstrapFile = "/tmp/does-not-exist.json"
+	f, err = cfg.NewFactory()
+	s.Nil(err)
+	_, err = f.ResolveAndPrepare(ch)
+	s.NotNil(err)
+}
+
+func (s *RingpopSuite) TestPreparedDiscoverProvider() {
+	file, err := ioutil.TempFile("", "ringpop-bootstrap")
+	s.Nil(err)
+	defer os.Remove(file.Name())
+	s.Nil(ioutil.WriteFile(file.Name(), []byte(`["10.0.0.1:7933"]`), 0644))
+
+	cfg := &Ringpop{Name: "test", BootstrapMode: BootstrapModeFile, BootstrapFile: file.Name()}
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+	ch, err := tcg.NewChannel("test", nil)
+	s.Nil(err)
+	defer ch.Close()
+	s.Nil(ch.ListenAndServe("127.0.0.1:0"))
+	prepared, err := f.ResolveAndPrepare(ch)
+	s.Nil(err)
+
+	// swim gets the prepared seeds first, then the file as it is now
+	provider := prepared.discoverProvider()
+	s.Nil(ioutil.WriteFile(file.Name(), []byte(`["10.0.0.2:7933", "10.0.0.3:7933"]`), 0644))
+	hosts, err := provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933"}, hosts)
+	hosts, err = provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.2:7933", "10.0.0.3:7933"}, hosts)
+
+	s.Nil(os.Remove(file.Name()))
+	_, err = provider.Hosts()
+	s.NotNil(err)
+}
+
+func (s *RingpopSuite) TestAdvertiseHostPort() {
+	_, ipNet4, _ := net.ParseCIDR("10.0.0.1/8")
+	ipNet4.IP = net.ParseIP("10.0.0.1")
+	_, ipNet6, _ := net.ParseCIDR("2001:db8::1/64")
+	ipNet6.IP = net.ParseIP("2001:db8::1")
+	loopback := &net.IPNet{IP: net.ParseIP("127.0.0.1"), Mask: net.CIDRMask(8, 32)}
+	interfaceAddrs := func(addrs ...net.Addr) func() ([]net.Addr, error) {
+		return func() ([]net.Addr, error) { return addrs, nil }
+	}
+
+	addr, err := advertiseHostPort("10.0.0.5:7933", "", 0, interfaceAddrs(ipNet4))
+	s.Nil(err)
+	s.Empty(addr)
+
+	addr, err = advertiseHostPort("0.0.0.0:7933", "", 0, interfaceAddrs(loopback, ipNet6, ipNet4))
+	s.Nil(err)
+	s.Equal("10.0.0.1:7933", addr)
+
+	addr, err = advertiseHostPort("[::]:7933", "", 0, interfaceAddrs(loopback, ipNet6))
+	s.Nil(err)
+	s.Equal("[2001:db8::1]:7933", addr)
+
+	_, err = advertiseHostPort("0.0.0.0:7933", "", 0, interfaceAddrs(loopback))
+	s.NotNil(err)
+	_, err = advertiseHostPort("0.0.0.0:7933", "", 0, func() ([]net.Addr, error) { return nil, errors.New("no interfaces") })
+	s.NotNil(err)
+
+	addr, err = advertiseHostPort("0.0.0.0:7933", "10.0.0.9", 0, interfaceAddrs())
+	s.Nil(err)
+	s.Equal("10.0.0.9:7933", addr)
+	addr, err = advertiseHostPort("10.0.0.5:7933", "10.0.0.9:8000", 0, interfaceAddrs())
+	s.Nil(err)
+	s.Equal("10.0.0.9:8000", addr)
+
+	s.Nil(validateAdvertiseAddress("", 0))
+	s.Nil(validateAdvertiseAddress("10.0.0.9", 0))
+	s.Nil(validateAdvertiseAddress("[2001:db8::1]:7933", 0))
+	s.NotNil(validateAdvertiseAddress("0.0.0.0", 0))
+	s.NotNil(validateAdvertiseAddress("cadence-0:7933", 0))
+
+	// only the port is remapped
+	addr, err = advertiseHostPort("10.0.0.5:7933", "", 17933, interfaceAddrs())
+	s.Nil(err)
+	s.Equal("10.0.0.5:17933", addr)
+	addr, err = advertiseHostPort("0.0.0.0:7933", "", 17933, interfaceAddrs(loopback, ipNet4))
+	s.Nil(err)
+	s.Equal("10.0.0.1:17933", addr)
+	addr, err = advertiseHostPort("0.0.0.0:7933", "10.0.0.9", 17933, interfaceAddrs())
+	s.Nil(err)
+	s.Equal("10.0.0.9:17933", addr)
+	addr, err = advertiseHostPort("10.0.0.5:7933", "", 7933, interfaceAddrs())
+	s.Nil(err)
+	s.Empty(addr)
+
+	s.Nil(validateAdvertiseAddress("10.0.0.9", 17933))
+	s.Nil(validateAdvertiseAddress("", 17933))
+	s.NotNil(validateAdvertiseAddress("", 70000))
+	s.NotNil(validateAdvertiseAddress("", -1))
+}
+
+func (s *RingpopSuite) TestRequiredMembers() {
+	members := []string{"10.0.0.1:7933", "[::ffff:10.0.0.2]:7933"}
+	s.Empty(missingMembers(members, []string{"10.0.0.2:7933", "10.0.0.1:7933"}))
+	s.Equal([]string{"10.0.0.3:7933"}, missingMembers(members, []string{"10.0.0.1:7933", "10.0.0.3:7933"}))
+
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.RequiredMembers = []string{"10.0.0.1"}
+	s.NotNil(cfg.validate())
+	cfg.RequiredMembers = []string{"10.0.0.1:7933"}
+	s.Nil(cfg.validate())
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+	s.Equal(ErrRingpopNotCreated, f.Ready())
+}
+
+func (s *RingpopSuite) TestMembershipOverlap() {
+	self := "10.0.0.1:7933"
+	members := []string{self, "10.0.0.2:7933"}
+	overlap, ok := membershipOverlap(members, []string{self, "10.0.0.2:7933", "10.0.0.3:7933"}, self)
+	s.True(ok)
+	s.Equal(0.5, overlap)
+	overlap, ok = membershipOverlap(members, []string{"[::ffff:10.0.0.2]:7933"}, self)
+	s.True(ok)
+	s.Equal(1.0, overlap)
+	_, ok = membershipOverlap(members, []string{self}, self)
+	s.False(ok)
+
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.ReconcileMinOverlap = 1.5
+	s.NotNil(cfg.validate())
+	cfg.ReconcileMinOverlap = 0
+	cfg.ReconcileInterval = -time.Second
+	s.NotNil(cfg.validate())
+	cfg.ReconcileInterval = time.Minute
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+	s.Equal(defaultReconcileMinOverlap, f.config.ReconcileMinOverlap)
+	f.Stop()
+}
+
+func (s *RingpopSuite) TestFactoryOptions() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getCustomConfig()), &cfg)
+	s.Nil(err)
+	_, err = NewFactory(&cfg)
+	s.NotNil(err)
+
+	logger := bark.NewLoggerFromLogrus(logrus.New())
+	scope := tally.NoopScope
+	provider := statichosts.New("127.0.0.1:1111")
+	f, err := NewFactory(&cfg, WithLogger(logger), WithMetrics(scope), WithProvider(provider))
+	s.Nil(err)
+	s.Equal(logger, f.logger)
+	s.Equal(scope, f.metricsScope)
+	s.Equal(provider, f.config.DiscoveryProvider)
+}
+
+func (s *RingpopSuite) TestClock() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	f, err := NewFactory(&cfg)
+	s.Nil(err)
+	s.Equal(realClock{}, f.clock)
+
+	clock := newFakeClock()
+	cfg.ReconcileInterval = time.Hour
+	f, err = NewFactory(&cfg, WithClock(clock))
+	s.Nil(err)
+	s.Equal(clock, f.clock)
+	s.Equal(clock.Now(), f.clock.Now())
+
+	// the reconcile loop only wakes up on the fake clock, so stopping
+	// the factory must not wait for the hour long interval to elapse
+	f.startReconciler(nil)
+	f.Stop()
+}
+
+func (s *RingpopSuite) TestExpectedJoinDuration() {
+	s.Equal(time.Duration(0), expectedJoinDuration(0, swimJoinTimeout))
+	s.Equal(swimJoinTimeout, expectedJoinDuration(1, swimJoinTimeout))
+	s.Equal(swimJoinTimeout, expectedJoinDuration(2, swimJoinTimeout))
+	s.Equal(15*swimJoinTimeout, expectedJoinDuration(30, swimJoinTimeout))
+	s.Equal(45*time.Second, expectedJoinDuration(30, 3*time.Second))
+}
+
+func (s *RingpopSuite) TestRingpopRequestTimeout() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+	s.Equal(swimJoinTimeout, f.joinTimeout())
+
+	cfg.RingpopRequestTimeout = -time.Second
+	s.NotNil(cfg.validate())
+	cfg.RingpopRequestTimeout = 3 * time.Second
+	f, err = cfg.NewFactory()
+	s.Nil(err)
+	s.Equal(3*time.Second, f.joinTimeout())
+}
+
+func (s *RingpopSuite) TestRetryBootstrap() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.BootstrapRetryAttempts = 2
+	clock := &firingClock{}
+	f, err := NewFactory(&cfg, WithClock(clock))
+	s.Nil(err)
+
+	attempts := 0
+	err = f.retryBootstrap(context.Background(), func() error {
+		attempts++
+		return errors.New("join failed")
+	})
+	s.IsType(&BootstrapRetryError{}, err)
+	s.Equal(retryLimitAttempts, err.(*BootstrapRetryError).Limit)
+	s.Equal(3, err.(*BootstrapRetryError).Attempts)
+	s.Equal(3, attempts)
+	s.Len(clock.waits(), 2)
+
+	attempts = 0
+	err = f.retryBootstrap(context.Background(), func() error {
+		attempts++
+		return &DNSLookupError{Host: "cadence", Err: errors.New(dnsNoSuchHost)}
+	})
+	s.NotNil(err)
+	s.Equal(1, attempts)
+
+	attempts = 0
+	err = f.retryBootstrap(context.Background(), func() error {
+		attempts++
+		if attempts < 2 {
+			return &DNSLookupError{Host: "cadence", Temporary: true, Err: errors.New("server misbehaving")}
+		}
+		return nil
+	})
+	s.Nil(err)
+	s.Equal(2, attempts)
+
+	cfg.BootstrapRetryAttempts = 0
+	attempts = 0
+	err = f.retryBootstrap(context.Background(), func() error {
+		attempts++
+		return errors.New("join failed")
+	})
+	s.NotNil(err)
+	s.Equal(1, attempts)
+}
+
+func (s *RingpopSuite) TestSelfOnlyAbort() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.SelfOnlyAbortThreshold = -1
+	s.NotNil(cfg.validate())
+	cfg.SelfOnlyAbortThreshold = 2
+	cfg.BootstrapRetryAttempts = 5
+	f, err := NewFactory(&cfg, WithClock(&firingClock{}))
+	s.Nil(err)
+
+	// a seed list with a peer resets the count of self only attempts
+	seeds := [][]string{{"10.0.0.1:7933"}, {"10.0.0.1:7933", "10.0.0.2:7933"}, {"[::ffff:10.0.0.1]:7933"}, nil, {"10.0.0.1:7933"}}
+	selfOnly := f.newSelfOnlyAbort()
+	attempts := 0
+	err = f.retryBootstrap(context.Background(), func() error {
+		attempts++
+		if err := selfOnly.check(seeds[attempts-1], "10.0.0.1:7933"); err != nil {
+			return err
+		}
+		return ErrRingpopSelfOnly
+	})
+	s.IsType(&SelfOnlyDiscoveryError{}, err)
+	s.Equal(2, err.(*SelfOnlyDiscoveryError).Attempts)
+	s.Equal(4, attempts)
+	s.Contains(err.Error(), "misconfigured")
+
+	cfg.FirstNode = true
+	s.Nil(f.newSelfOnlyAbort().check(nil, "10.0.0.1:7933"))
+	cfg.FirstNode = false
+	cfg.SelfOnlyAbortThreshold = 0
+	s.Nil(f.newSelfOnlyAbort().check(nil, "10.0.0.1:7933"))
+}
+
+func (s *RingpopSuite) TestRetryBootstrapLimits() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.BootstrapRetryMaxInterval = time.Minute
+	cfg.BootstrapRetryMaxElapsed = 30 * time.Second
+	s.NotNil(cfg.validate())
+	cfg.BootstrapRetryMaxElapsed = -time.Second
+	s.NotNil(cfg.validate())
+
+	// the backoff is capped and the retries stop once the elapsed time runs out
+	cfg.BootstrapRetryMaxInterval = 4 * time.Second
+	cfg.BootstrapRetryMaxElapsed = 20 * time.Second
+	clock := &firingClock{}
+	f, err := NewFactory(&cfg, WithClock(clock))
+	s.Nil(err)
+	attempts := 0
+	err = f.retryBootstrap(context.Background(), func() error {
+		attempts++
+		return errors.New("join failed")
+	})
+	s.IsType(&BootstrapRetryError{}, err)
+	s.Equal(retryLimitElapsed, err.(*BootstrapRetryError).Limit)
+	s.Equal(attempts, err.(*BootstrapRetryError).Attempts)
+	s.Contains(err.Error(), "`bootstrapRetryMaxElapsed` reached: join failed")
+	s.True(attempts > 3)
+	for _, wait := range clock.waits() {
+		s.True(wait <= cfg.BootstrapRetryMaxInterval)
+	}
+	s.True(clock.Now().Sub(time.Time{}) <= cfg.BootstrapRetryMaxElapsed)
+}
+
+func (s *RingpopSuite) TestTracer() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getJSONConfig()), &cfg)
+	s.Nil(err)
+	cfg.BootstrapFile = "/tmp/does-not-exist.json"
+	cfg.BootstrapRetryAttempts = 1
+	tracer := &recordingTracer{}
+	f, err := NewFactory(&cfg, WithClock(&firingClock{}), WithTracer(tracer))
+	s.Nil(err)
+
+	ctx := context.WithValue(context.Background(), recordingParentKey, "startup")
+	_, err = f.createRingpop(ctx, nil, 0)
+	s.NotNil(err)
+	s.Len(tracer.spans, 3)
+	for _, span := range tracer.spans[:2] {
+		s.Equal(spanDiscovery, span.name)
+		s.Equal(spanBootstrap, span.parent)
+		s.NotNil(span.err)
+	}
+	s.Equal(1, tracer.spans[0].attributes["ringpop.attempt"])
+	s.Equal(2, tracer.spans[1].attributes["ringpop.attempt"])
+
+	bootstrap := tracer.spans[2]
+	s.Equal(spanBootstrap, bootstrap.name)
+	s.Equal("startup", bootstrap.parent)
+	s.Equal("file", bootstrap.attributes["ringpop.mode"])
+	s.Equal(2, bootstrap.attributes["ringpop.attempts"])
+	s.Equal(err, bootstrap.err)
+}
+
+func (s *RingpopSuite) TestDNSLookupError() {
+	err := newDNSLookupError("cadence", &net.DNSError{Err: dnsNoSuchHost, Name: "cadence"})
+	s.False(err.Temporary)
+	s.False(isRetryableBootstrapError(err))
+	err = newDNSLookupError("cadence", &net.DNSError{Err: "i/o timeout", Name: "cadence", IsTimeout: true})
+	s.True(err.Temporary)
+	s.True(isRetryableBootstrapError(err))
+	s.False(isRetryableBootstrapError(context.Canceled))
+
+	var cfg Ringpop
+	err2 := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err2)
+	cfg.BootstrapMode = BootstrapModeDNS
+	cfg.BootstrapHosts = []string{"localhost"}
+	f, err2 := NewFactory(&cfg)
+	s.Nil(err2)
+	provider, err2 := f.discoveryProvider()
+	s.Nil(err2)
+	_, err2 = provider.Hosts()
+	s.NotNil(err2)
+}
+
+func (s *RingpopSuite) TestReadinessDebounce() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.ReadinessDebounce = 10 * time.Second
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	f, err := NewFactory(&cfg, WithClock(clock))
+	s.Nil(err)
+
+	// a node that has never been ready isn't debounced
+	missingErr := errors.New("ringpop is missing required members: 127.0.0.1:7933")
+	s.Equal(missingErr, f.debounceReadiness(missingErr))
+	clock.advance(time.Minute)
+	s.Equal(missingErr, f.debounceReadiness(missingErr))
+
+	// once ready, losing members is debounced
+	s.Nil(f.debounceReadiness(nil))
+	s.Nil(f.debounceReadiness(missingErr))
+	clock.advance(9 * time.Second)
+	s.Nil(f.debounceReadiness(missingErr))
+	clock.advance(time.Second)
+	s.Equal(missingErr, f.debounceReadiness(missingErr))
+	// and stays reported until the node is ready again
+	s.Equal(missingErr, f.debounceReadiness(missingErr))
+
+	// recovery is reported immediately and restarts the debounce
+	s.Nil(f.debounceReadiness(nil))
+	clock.advance(time.Second)
+	s.Nil(f.debounceReadiness(missingErr))
+
+	cfg.ReadinessDebounce = 0
+	s.Equal(missingErr, f.debounceReadiness(missingErr))
+
+	cfg.ReadinessDebounce = -time.Second
+	s.NotNil(cfg.validate())
+}
+
+func (s *RingpopSuite) TestBootstrapProgress() {
+	progress := newBootstrapProgress(3, time.Unix(1000, 0))
+	progress.HandleEvent(swim.JoinTriesUpdateEvent{Retries: 2})
+	progress.HandleEvent(swim.JoinCompleteEvent{NumJoined: 1})
+	fields := progress.fields(time.Unix(1005, 0))
+	s.Equal(3, fields["seeds"])
+	s.Equal(2, fields["joinAttempts"])
+	s.Equal(1, fields["joined"])
+	s.Equal(5*time.Second, fields["elapsed"])
+
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.BootstrapProgressInterval = time.Second
+	clock := newFakeClock()
+	f, err := NewFactory(&cfg, WithClock(clock))
+	s.Nil(err)
+
+	doneC := make(chan struct{})
+	exitedC := make(chan struct{})
+	go func() {
+		f.logBootstrapProgress(progress, doneC)
+		close(exitedC)
+	}()
+	clock.afterC <- time.Unix(1001, 0)
+	clock.afterC <- time.Unix(1002, 0)
+	close(doneC)
+	<-exitedC
+
+	cfg.BootstrapProgressInterval = -time.Second
+	s.NotNil(cfg.validate())
+}
+
+func (s *RingpopSuite) TestSelfOnly() {
+	s.True(isSelfOnly(nil, "10.0.0.1:7933"))
+	s.True(isSelfOnly([]string{"10.0.0.1:7933"}, "10.0.0.1:7933"))
+	s.False(isSelfOnly([]string{"10.0.0.1:7933", "10.0.0.2:7933"}, "10.0.0.1:7933"))
+
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.SelfOnlyBootstrapPolicy = "Fail"
+	s.Nil(cfg.validate())
+	cfg.SelfOnlyBootstrapPolicy = "panic"
+	s.NotNil(cfg.validate())
+}
+
+func (s *RingpopSuite) TestNextReconcileInterval() {
+	base, max := 10*time.Second, time.Minute
+	s.Equal(20*time.Second, nextReconcileInterval(base, base, max, true))
+	s.Equal(40*time.Second, nextReconcileInterval(20*time.Second, base, max, true))
+	s.Equal(max, nextReconcileInterval(40*time.Second, base, max, true))
+	s.Equal(max, nextReconcileInterval(max, base, max, true))
+	s.Equal(base, nextReconcileInterval(max, base, max, false))
+	s.Equal(base, nextReconcileInterval(base, base, 0, true))
+}
+
+func (s *RingpopSuite) TestNameCasing() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.Name = "Cadence"
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+	s.Equal("Cadence", f.config.Name)
+	s.Equal("Cadence", f.config.AppName)
+
+	cfg.Name, cfg.AppName, cfg.NameCasing = "Cadence", "", "lower"
+	f, err = cfg.NewFactory()
+	s.Nil(err)
+	s.Equal("cadence", f.config.Name)
+	s.Equal("cadence", f.config.AppName)
+
+	cfg.Name, cfg.AppName, cfg.NameCasing = "Cadence", "", "strict"
+	_, err = cfg.NewFactory()
+	s.NotNil(err)
+	cfg.Name, cfg.AppName = "cadence", "Frontend"
+	_, err = cfg.NewFactory()
+	s.NotNil(err)
+	cfg.AppName = "frontend"
+	_, err = cfg.NewFactory()
+	s.Nil(err)
+
+	cfg.NameCasing = "upper"
+	s.NotNil(cfg.validate())
+}
+
+func (s *RingpopSuite) TestWarmup() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.BootstrapWarmup = 2 * time.Second
+	clock := &firingClock{}
+	f, err := NewFactory(&cfg, WithClock(clock))
+	s.Nil(err)
+	s.Equal(cfg.MaxJoinDuration, cfg.BootstrapWarmupMax)
+
+	// the member count grows for 3s and then settles
+	counts := []int{1, 2, 3, 4, 5, 6, 7}
+	f.warmup(context.Background(), func() (int, error) {
+		count := counts[0]
+		if len(counts) > 1 {
+			counts = counts[1:]
+		}
+		return count, nil
+	})
+	s.Equal(5*time.Second, clock.Now().Sub(time.Time{}))
+
+	// the member count never settles, warmup is capped
+	clock = &firingClock{}
+	f.clock = clock
+	count := 0
+	f.warmup(context.Background(), func() (int, error) {
+		count++
+		return count, nil
+	})
+	s.Equal(cfg.BootstrapWarmupMax, clock.Now().Sub(time.Time{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	f.clock = newFakeClock()
+	f.warmup(ctx, func() (int, error) { return 1, nil })
+}
+
+func (s *RingpopSuite) TestDiscoveryInitialDelay() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.DiscoveryInitialDelay = -time.Second
+	s.NotNil(cfg.validate())
+	cfg.DiscoveryInitialDelay = cfg.MaxJoinDuration + time.Second
+	s.NotNil(cfg.validate())
+	cfg.DiscoveryInitialDelay = 2 * time.Second
+	s.Nil(cfg.validate())
+
+	// the seeds grow once and then settle
+	provider := &growingProvider{batches: [][]string{{"10.0.0.1:7933"}, {"10.0.0.1:7933", "10.0.0.2:7933"}}}
+	clock := &firingClock{}
+	f, err := NewFactory(&cfg, WithClock(clock), WithProvider(provider))
+	s.Nil(err)
+	s.Nil(f.settleDiscovery(context.Background()))
+	s.Equal(time.Second, clock.Now().Sub(time.Time{}))
+	s.Equal(3, provider.calls)
+
+	// the seeds never show up, the delay is capped
+	clock = &firingClock{}
+	f, err = NewFactory(&cfg, WithClock(clock), WithProvider(&flakyProvider{fail: true}))
+	s.Nil(err)
+	s.Nil(f.settleDiscovery(context.Background()))
+	s.Equal(cfg.DiscoveryInitialDelay, clock.Now().Sub(time.Time{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	f.clock = newFakeClock()
+	s.Equal(context.Canceled, f.settleDiscovery(ctx))
+}
+
+func (s *RingpopSuite) TestSetDefaultMaxJoinDuration() {
+	defer SetDefaultMaxJoinDuration(getDefaultMaxJoinDuration())
+	s.NotNil(SetDefaultMaxJoinDuration(0))
+	s.NotNil(SetDefaultMaxJoinDuration(-time.Second))
+	s.Nil(SetDefaultMaxJoinDuration(45 * time.Second))
+
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.MaxJoinDuration = 0
+	_, err = cfg.NewFactory()
+	s.Nil(err)
+	s.Equal(45*time.Second, cfg.MaxJoinDuration)
+
+	cfg.MaxJoinDuration = 5 * time.Second
+	_, err = cfg.NewFactory()
+	s.Nil(err)
+	s.Equal(5*time.Second, cfg.MaxJoinDuration)
+}
+
+func (s *RingpopSuite) TestEffectiveConfig() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.MaxJoinDuration = 0
+	f, err := NewFactory(&cfg, WithProvider(statichosts.New("10.0.0.1:7933")))
+	s.Nil(err)
+
+	effective := f.EffectiveConfig()
+	s.Equal(getDefaultMaxJoinDuration(), effective.MaxJoinDuration)
+	s.Equal(cfg.Name, effective.AppName)
+	s.Equal(defaultBootstrapRetryInterval, effective.BootstrapRetryInterval)
+	s.Equal(cfg.BootstrapHosts, effective.BootstrapHosts)
+
+	// the copy doesn't alias the factory config
+	effective.BootstrapHosts[0] = "10.0.0.9:7933"
+	s.NotEqual("10.0.0.9:7933", f.EffectiveConfig().BootstrapHosts[0])
+
+	f, err = NewFactory(&Ringpop{Name: "test"}, WithProvider(statichosts.New("10.0.0.1:7933")))
+	s.Nil(err)
+	s.Equal(BootstrapModeCustom, f.EffectiveConfig().BootstrapMode)
+	s.Equal(BootstrapModeCustom, f.ResolvedBootstrapMode())
+}
+
+func (s *RingpopSuite) TestBootstrapModeString() {
+	for _, mode := range []string{"hosts", "file", "custom", "composite", "dns", "s3", "nomad", "peer", "zk", "k8spods", "mdns", "etcd", "dnssrv", "systemdcreds", "blend", "mmap", "frontend", "vault"} {
+		parsed, err := parseBootstrapMode(mode)
+		s.Nil(err)
+		s.Equal(mode, parsed.String())
+	}
+	s.Equal("none", BootstrapModeNone.String())
+}
+
+func (s *RingpopSuite) TestParseBootstrapMode() {
+	mode, err := parseBootstrapMode("  Hosts\n")
+	s.Nil(err)
+	s.Equal(BootstrapModeHosts, mode)
+	for _, invalid := range []string{"", " ", "none", "host s", strings.Repeat("hosts", 10000)} {
+		mode, err = parseBootstrapMode(invalid)
+		s.NotNil(err)
+		s.Equal(BootstrapModeNone, mode)
+	}
+
+	// any input either parses to a mode that round trips or fails with no mode
+	roundTrips := func(input string) bool {
+		mode, err := parseBootstrapMode(input)
+		if err != nil {
+			return mode == BootstrapModeNone
+		}
+		return mode != BootstrapModeNone && mode.String() == strings.ToLower(strings.TrimSpace(input))
+	}
+	s.Nil(quick.Check(roundTrips, &quick.Config{MaxCount: 10000}))
+
+	// every canonical mode is reachable whatever its casing and padding
+	modes := []string{"hosts", "file", "custom", "composite", "dns", "s3", "nomad", "peer", "zk", "k8spods", "mdns", "etcd", "dnssrv", "systemdcreds", "blend", "mmap", "frontend", "vault"}
+	reachable := func(index uint8, padding uint8, upper bool) bool {
+		name := modes[int(index)%len(modes)]
+		input := name
+		if upper {
+			input = strings.ToUpper(input)
+		}
+		input = strings.Repeat(" ", int(padding)%4) + input + strings.Repeat("\t", int(padding)%3)
+		mode, err := parseBootstrapMode(input)
+		return err == nil && mode.String() == name
+	}
+	s.Nil(quick.Check(reachable, nil))
+}
+
+func (s *RingpopSuite) TestMaxConcurrentDiscovery() {
+	defer SetMaxConcurrentDiscovery(0)
+	s.NotNil(SetMaxConcurrentDiscovery(-1))
+
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	f, err := NewFactory(&cfg, WithClock(&firingClock{}))
+	s.Nil(err)
+	release, err := f.acquireDiscoverySlot(context.Background())
+	s.Nil(err)
+	release()
+
+	s.Nil(SetMaxConcurrentDiscovery(1))
+	release, err = f.acquireDiscoverySlot(context.Background())
+	s.Nil(err)
+	_, err = f.acquireDiscoverySlot(context.Background())
+	s.NotNil(err)
+	s.Contains(err.Error(), "1 concurrent discovery slots")
+
+	f.clock = newFakeClock()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = f.acquireDiscoverySlot(ctx)
+	s.Equal(context.Canceled, err)
+
+	release()
+	release, err = f.acquireDiscoverySlot(context.Background())
+	s.Nil(err)
+	release()
+}
+
+func (s *RingpopSuite) TestDiscoveryRateLimit() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.DiscoveryQPS = 0.1
+	clock := &firingClock{fakeClock: fakeClock{now: time.Unix(1000, 0)}}
+	f, err := NewFactory(&cfg, WithClock(clock))
+	s.Nil(err)
+	s.Equal(defaultDiscoveryBurst, cfg.DiscoveryBurst)
+
+	provider, err := f.discoveryProvider()
+	s.Nil(err)
+	hosts, err := provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"127.0.0.1:1111"}, hosts)
+	s.Empty(clock.waits())
+
+	// the next token is 10s away, within the 30s timeout
+	hosts, err = provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"127.0.0.1:1111"}, hosts)
+	s.Equal([]time.Duration{10 * time.Second}, clock.waits())
+
+	cfg.DiscoveryQPS = 0.01
+	f, err = NewFactory(&cfg, WithClock(clock))
+	s.Nil(err)
+	provider, err = f.discoveryProvider()
+	s.Nil(err)
+	_, err = provider.Hosts()
+	s.Nil(err)
+	_, err = provider.Hosts()
+	s.NotNil(err)
+	s.Contains(err.Error(), "rate limited")
+
+	cfg.DiscoveryQPS = -1
+	s.NotNil(cfg.validate())
+}
+
+func (s *RingpopSuite) TestRingpopOptions() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+	s.Len(f.ringpopOptions(nil, "", 3), 1)
+	s.Len(f.ringpopOptions(nil, "10.0.0.1:7933", 3), 2)
+
+	cfg.DisableFaultyReaping = true
+	s.Len(f.ringpopOptions(nil, "10.0.0.1:7933", 3), 3)
+
+	cfg.SuspicionTimeoutBase = time.Second
+	s.Len(f.ringpopOptions(nil, "10.0.0.1:7933", 3), 4)
+
+	cfg.NamePrefix = "tenant1-"
+	s.Len(f.ringpopOptions(nil, "10.0.0.1:7933", 3), 5)
+
+	cfg.HashRingReplicaPoints = 200
+	s.Len(f.ringpopOptions(nil, "10.0.0.1:7933", 3), 6)
+	cfg.HashRingReplicaPoints = -1
+	s.NotNil(cfg.validate())
+	cfg.HashRingReplicaPoints = 0
+
+	cfg.TombstonePeriod = time.Minute
+	cfg.LabelLimitCount = 4
+	cfg.LabelLimitValueSize = 16
+	s.Len(f.ringpopOptions(nil, "10.0.0.1:7933", 3), 8)
+	s.Nil(cfg.validate())
+	cfg.Partition = "a-partition-longer-than-the-limit"
+	s.NotNil(cfg.validate())
+	cfg.Partition = ""
+	cfg.LabelLimitCount = 1
+	s.NotNil(cfg.validate())
+}
+
+func (s *RingpopSuite) TestWithRingpopOptions() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.SuspicionTimeoutBase = time.Second
+	var applied []string
+	option := func(name string) ringpop.Option {
+		return func(*ringpop.Ringpop) error {
+			applied = append(applied, name)
+			return nil
+		}
+	}
+	f, err := NewFactory(&cfg, WithRingpopOptions(option("a")), WithRingpopOptions(option("b"), option("c")))
+	s.Nil(err)
+
+	// the injected options come last, after the config derived ones
+	opts := f.ringpopOptions(nil, "10.0.0.1:7933", 3)
+	s.Len(opts, 6)
+	for _, opt := range opts[3:] {
+		s.Nil(opt(nil))
+	}
+	s.Equal([]string{"a", "b", "c"}, applied)
+}
+
+func (s *RingpopSuite) TestNamePrefix() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.NamePrefix = "tenant 1"
+	s.NotNil(cfg.validate())
+
+	cfg.NamePrefix = "tenant1-"
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+	s.Equal("tenant1-"+cfg.AppName, f.ringAppName())
+	s.Equal("tenant1-", f.EffectiveConfig().NamePrefix)
+}
+
+func (s *RingpopSuite) TestSuspicionTimeout() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+	s.Equal(time.Duration(0), f.suspicionTimeout(10))
+
+	cfg.SuspicionTimeoutBase = 2 * time.Second
+	cfg.SuspicionTimeoutPerMember = 100 * time.Millisecond
+	s.Equal(2*time.Second, f.suspicionTimeout(0))
+	s.Equal(3*time.Second, f.suspicionTimeout(10))
+
+	cfg.SuspicionTimeoutMax = 5 * time.Second
+	s.Equal(5*time.Second, f.suspicionTimeout(100))
+	s.Nil(cfg.validate())
+
+	cfg.SuspicionTimeoutMax = time.Second
+	s.NotNil(cfg.validate())
+	cfg.SuspicionTimeoutMax = 0
+	cfg.SuspicionTimeoutPerMember = -time.Second
+	s.NotNil(cfg.validate())
+}
+
+func (s *RingpopSuite) TestAddressTransform() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.BootstrapHosts = []string{"10.0.0.1:7933", "10.0.0.2:7933"}
+	transform := func(addr string) (string, error) {
+		if addr == "10.0.0.3:7933" {
+			return "", errors.New("no mapping")
+		}
+		return strings.Replace(addr, "10.0.0.", "192.168.0.", 1), nil
+	}
+	f, err := NewFactory(&cfg, WithAddressTransform(transform))
+	s.Nil(err)
+
+	provider, err := f.discoveryProvider()
+	s.Nil(err)
+	hosts, err := provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"192.168.0.1:7933", "192.168.0.2:7933"}, hosts)
+
+	addr, err := f.transformAdvertiseAddress("10.0.0.5:7933", "")
+	s.Nil(err)
+	s.Equal("192.168.0.5:7933", addr)
+	addr, err = f.transformAdvertiseAddress("127.0.0.1:7933", "")
+	s.Nil(err)
+	s.Empty(addr)
+
+	cfg.BootstrapHosts = append(cfg.BootstrapHosts, "10.0.0.3:7933")
+	provider, err = f.discoveryProvider()
+	s.Nil(err)
+	_, err = provider.Hosts()
+	s.NotNil(err)
+	s.Contains(err.Error(), "10.0.0.3:7933")
+	_, err = f.transformAdvertiseAddress("0.0.0.0:7933", "10.0.0.3:7933")
+	s.NotNil(err)
+	s.Contains(err.Error(), "10.0.0.3:7933")
+}
+
+func (s *RingpopSuite) TestSeedScorer() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.BootstrapHosts = []string{"10.0.0.1:7933", "10.0.0.2:7933", "10.0.0.3:7933", "10.0.0.4:7933"}
+	scores := map[string]float64{"10.0.0.2:7933": 1, "10.0.0.4:7933": 1, "10.0.0.3:7933": 2}
+	f, err := NewFactory(&cfg, WithSeedScorer(func(addr string) float64 {
+		return scores[addr]
+	}))
+	s.Nil(err)
+
+	provider, err := f.discoveryProvider()
+	s.Nil(err)
+	hosts, err := provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.3:7933", "10.0.0.2:7933", "10.0.0.4:7933", "10.0.0.1:7933"}, hosts)
+
+	scores["10.0.0.1:7933"] = 3
+	hosts, err = provider.Hosts()
+	s.Nil(err)
+	s.Equal("10.0.0.1:7933", hosts[0])
+}
+
+func (s *RingpopSuite) TestSeedListTransform() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.BootstrapHosts = []string{"10.0.0.1:7933", "10.0.0.2:7933"}
+	var received []string
+	var transformErr error
+	f, err := NewFactory(&cfg,
+		WithSeedScorer(func(addr string) float64 {
+			if addr == "10.0.0.2:7933" {
+				return 1
+			}
+			return 0
+		}),
+		WithSeedListTransform(func(hosts []string) ([]string, error) {
+			received = hosts
+			return hosts[:1], transformErr
+		}))
+	s.Nil(err)
+
+	provider, err := f.discoveryProvider()
+	s.Nil(err)
+	hosts, err := provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.2:7933", "10.0.0.1:7933"}, received)
+	s.Equal([]string{"10.0.0.2:7933"}, hosts)
+
+	transformErr = errors.New("bespoke")
+	_, err = provider.Hosts()
+	s.NotNil(err)
+	s.Contains(err.Error(), "transform failed on 2 seeds: bespoke")
+}
+
+func (s *RingpopSuite) TestSeedValidator() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.BootstrapHosts = []string{"10.0.0.1:7933", "10.0.0.2:7933"}
+	f, err := NewFactory(&cfg,
+		WithSeedListTransform(func(hosts []string) ([]string, error) {
+			return hosts[1:], nil
+		}),
+		WithSeedValidator(func(hosts []string) error {
+			if len(hosts) < 2 {
+				return fmt.Errorf("expected at least 2 seeds, got %v", len(hosts))
+			}
+			return nil
+		}))
+	s.Nil(err)
+
+	provider, err := f.discoveryProvider()
+	s.Nil(err)
+	_, err = provider.Hosts()
+	s.NotNil(err)
+	s.Contains(err.Error(), "validation failed on 1 seeds: expected at least 2 seeds, got 1")
+
+	cfg.BootstrapHosts = append(cfg.BootstrapHosts, "10.0.0.3:7933")
+	provider, err = f.discoveryProvider()
+	s.Nil(err)
+	hosts, err := provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.2:7933", "10.0.0.3:7933"}, hosts)
+}
+
+func (s *RingpopSuite) TestMaxMembersValidation() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.MaxMembers = 100
+	cfg.MaxMembersPolicy = "Leave"
+	s.Nil(cfg.validate())
+	cfg.MaxMembersPolicy = "fail"
+	s.NotNil(cfg.validate())
+	cfg.MaxMembersPolicy = ""
+	cfg.MaxMembers = -1
+	s.NotNil(cfg.validate())
+	cfg.MaxMembers = 100
+	cfg.MaxMembersCheckInterval = -time.Second
+	s.NotNil(cfg.validate())
+}
+
+func (s *RingpopSuite) TestMaxMembersMonitor() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.MaxMembers = 3
+	clock := &firingClock{}
+	f, err := NewFactory(&cfg, WithClock(clock))
+	s.Nil(err)
+	s.Equal(defaultMaxMembersCheckInterval, cfg.MaxMembersCheckInterval)
+
+	counts := []int{2, 3, 5}
+	count := func() (int, error) {
+		c := counts[0]
+		if len(counts) > 1 {
+			counts = counts[1:]
+		}
+		return c, nil
+	}
+	left := 0
+	leave := func() error {
+		left++
+		return nil
+	}
+
+	// the warn policy stays in the ring
+	s.Nil(f.applyMaxMembersPolicy(func() (int, error) { return 5, nil }, leave))
+	s.Equal(0, left)
+
+	// the leave policy leaves once the count exceeds max members
+	cfg.MaxMembersPolicy = maxMembersPolicyLeave
+	f.shutdownWG.Add(1)
+	f.maxMembersLoop(count, leave)
+	s.Equal(1, left)
+	s.Len(clock.waits(), 3)
+
+	// and Ready reports it from then on
+	ch, err := tcg.NewChannel("cadence-test", nil)
+	s.Nil(err)
+	defer ch.Close()
+	rp, err := ringpop.New("test", ringpop.Channel(ch))
+	s.Nil(err)
+	defer rp.Destroy()
+	f.mutex.Lock()
+	f.channel, f.ringpop = ch, rp
+	f.mutex.Unlock()
+	err = f.Ready()
+	s.IsType(&MaxMembersError{}, err)
+	s.Equal(&MaxMembersError{Count: 5, MaxMembers: 3}, err)
+	s.Equal(err, f.Healthy())
+}
+
+func (s *RingpopSuite) TestMerge() {
+	base := &Ringpop{
+		Name:            "cadence",
+		BootstrapMode:   BootstrapModeHosts,
+		BootstrapHosts:  []string{"10.0.0.1:7933", "10.0.0.2:7933"},
+		MaxJoinDuration: 10 * time.Second,
+		RequiredMembers: []string{"10.0.0.1:7933"},
+		FirstNode:       true,
+	}
+	override := &Ringpop{
+		BootstrapHosts:         []string{"10.1.0.1:7933"},
+		BootstrapEtcdEndpoints: []string{"http://etcd-0:2379"},
+		MaxJoinDuration:        30 * time.Second,
+		ReadinessDebounce:      5 * time.Second,
+	}
+
+	merged := base.Merge(override)
+	s.Equal("cadence", merged.Name)
+	s.Equal(BootstrapModeHosts, merged.BootstrapMode)
+	s.Equal([]string{"10.1.0.1:7933"}, merged.BootstrapHosts)
+	s.Equal(30*time.Second, merged.MaxJoinDuration)
+	s.Equal(5*time.Second, merged.ReadinessDebounce)
+	s.Equal([]string{"10.0.0.1:7933"}, merged.RequiredMembers)
+	s.True(merged.FirstNode)
+
+	// the merged config doesn't alias either config
+	merged.BootstrapHosts[0] = "10.2.0.1:7933"
+	merged.RequiredMembers[0] = "10.2.0.1:7933"
+	merged.BootstrapEtcdEndpoints[0] = "http://etcd-1:2379"
+	s.Equal("10.1.0.1:7933", override.BootstrapHosts[0])
+	s.Equal("10.0.0.1:7933", base.RequiredMembers[0])
+	s.Equal("http://etcd-0:2379", override.BootstrapEtcdEndpoints[0])
+
+	// nor is it changed along with the source configs
+	merged = base.Merge(override)
+	override.BootstrapEtcdEndpoints[0] = "http://etcd-2:2379"
+	s.Equal("http://etcd-0:2379", merged.BootstrapEtcdEndpoints[0])
+	s.Equal(10*time.Second, base.MaxJoinDuration)
+
+	s.Equal(base.BootstrapHosts, base.Merge(nil).BootstrapHosts)
+}
+
+func (s *RingpopSuite) TestFileStaleness() {
+	file, err := ioutil.TempFile("", "ringpop-bootstrap")
+	s.Nil(err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(`["10.0.0.1:7933"]`)
+	s.Nil(err)
+	s.Nil(file.Close())
+
+	cfg := &Ringpop{
+		Name:                   "test",
+		BootstrapMode:          BootstrapModeFile,
+		BootstrapFile:          file.Name(),
+		BootstrapFileStaleness: time.Minute,
+	}
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	f, err := NewFactory(cfg, WithClock(clock))
+	s.Nil(err)
+
+	info, err := os.Stat(file.Name())
+	s.Nil(err)
+	s.False(f.fileStaleness.observe(info, clock.Now(), time.Minute))
+	clock.advance(59 * time.Second)
+	s.False(f.fileStaleness.observe(info, clock.Now(), time.Minute))
+	clock.advance(time.Second)
+	s.True(f.fileStaleness.observe(info, clock.Now(), time.Minute))
+	// a stale file is only re-read once per window
+	s.False(f.fileStaleness.observe(info, clock.Now(), time.Minute))
+
+	// a change restarts the window
+	modTime := info.ModTime().Add(time.Second)
+	s.Nil(os.Chtimes(file.Name(), modTime, modTime))
+	info, err = os.Stat(file.Name())
+	s.Nil(err)
+	clock.advance(time.Minute)
+	s.False(f.fileStaleness.observe(info, clock.Now(), time.Minute))
+
+	clock.advance(time.Minute)
+	provider, err := f.discoveryProvider()
+	s.Nil(err)
+	hosts, err := provider.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933"}, hosts)
+
+	cfg.BootstrapFileStaleness = -time.Second
+	s.NotNil(cfg.validate())
+}
+
+func (s *RingpopSuite) TestWaitForMembership() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	clock := &firingClock{}
+	f, err := NewFactory(&cfg, WithClock(clock))
+	s.Nil(err)
+	s.Equal(defaultMembershipPollInterval, cfg.MembershipPollInterval)
+
+	polls := 0
+	getMembers := func() ([]string, error) {
+		polls++
+		if polls == 2 {
+			return nil, errors.New("not bootstrapped")
+		}
+		members := []string{"10.0.0.1:7933"}
+		if polls > 3 {
+			members = append(members, "10.0.0.2:7933")
+		}
+		return members, nil
+	}
+	err = f.waitForMembership(context.Background(), getMembers, func(members []string) bool {
+		return len(members) >= 2
+	})
+	s.Nil(err)
+	s.Equal(4, polls)
+	s.Equal([]time.Duration{time.Second, time.Second, time.Second}, clock.waits())
+
+	f.clock = newFakeClock()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = f.waitForMembership(ctx, getMembers, func([]string) bool { return false })
+	s.Equal(context.Canceled, err)
+}
+
+func (s *RingpopSuite) TestStartupBarrier() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	f, err := NewFactory(&cfg, WithClock(&firingClock{}))
+	s.Nil(err)
+	s.Equal(ErrRingpopNotCreated, f.StartupBarrier(context.Background(), []string{"10.0.0.1:7933"}))
+
+	polls := 0
+	getMembers := func() ([]string, error) {
+		polls++
+		members := []string{"10.0.0.1:7933"}
+		if polls > 1 {
+			members = append(members, "[::ffff:10.0.0.2]:7933")
+		}
+		return members, nil
+	}
+	expected := []string{"10.0.0.1:7933", "10.0.0.2:7933"}
+	s.Nil(f.startupBarrier(context.Background(), getMembers, expected))
+	s.Equal(2, polls)
+
+	f.clock = newFakeClock()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = f.startupBarrier(ctx, getMembers, append(expected, "10.0.0.3:7933"))
+	s.IsType(&StartupBarrierError{}, err)
+	s.Equal([]string{"10.0.0.3:7933"}, err.(*StartupBarrierError).Missing)
+	s.Equal(context.Canceled, err.(*StartupBarrierError).Err)
+}
+
+func (s *RingpopSuite) TestCreateRingpopOwnChannelValidation() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+
+	for _, addr := range []string{"", "127.0.0.1", "localhost:7933", "127.0.0.1:port", "127.0.0.1:70000"} {
+		_, err = f.CreateRingpopOwnChannel(addr)
+		s.NotNil(err, addr)
+	}
+	s.Nil(validateListenAddress("127.0.0.1:7933", 0))
+	s.Nil(validateListenAddress(":7933", 0))
+
+	// destroying a factory that never created ringpop is a no-op
+	f.Destroy()
+	f.Destroy()
+}
+
+func (s *RingpopSuite) TestPartition() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.Partition = "zone-a"
+	s.Nil(cfg.validate())
+	cfg.Partition = "zone a"
+	s.NotNil(cfg.validate())
+	cfg.Partition = strings.Repeat("a", maxPartitionLength+1)
+	s.NotNil(cfg.validate())
+
+	cfg.Partition = "zone-a"
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+	_, err = f.MembersInPartition("zone-a")
+	s.Equal(ErrRingpopNotCreated, err)
+	_, err = f.MembersInPartition("zone/a")
+	s.NotNil(err)
+}
+
+func (s *RingpopSuite) TestIsMember() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+	_, err = f.IsMember("10.0.0.1:7933")
+	s.Equal(ErrRingpopNotCreated, err)
+
+	member := swim.Member{Address: "10.0.0.1:7933"}
+	for _, addr := range []string{"10.0.0.1:7933", "[::ffff:10.0.0.1]:7933"} {
+		s.True(memberWithAddress(addr)(member), addr)
+	}
+	s.False(memberWithAddress("10.0.0.1:7934")(member))
+	s.False(memberWithAddress("10.0.0.2:7933")(member))
+
+	member = swim.Member{Address: "cadence-0.example.com:7933"}
+	s.True(memberWithAddress("Cadence-0.example.com.:7933")(member))
+}
+
+func (s *RingpopSuite) TestPortAutoIncrement() {
+	s.Nil(validateListenAddress("127.0.0.1:7933", 10))
+	s.NotNil(validateListenAddress("127.0.0.1:0", 10))
+	s.NotNil(validateListenAddress("127.0.0.1:65530", 10))
+
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.PortAutoIncrement = maxPortAutoIncrement + 1
+	s.NotNil(cfg.validate())
+
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Nil(err)
+	defer taken.Close()
+	addr := taken.Addr().String()
+
+	_, err = listenWithAutoIncrement(addr, 0)
+	s.NotNil(err)
+
+	listener, err := listenWithAutoIncrement(addr, 5)
+	if err != nil {
+		// the next ports may all be taken on a busy host
+		return
+	}
+	defer listener.Close()
+	s.NotEqual(addr, listener.Addr().String())
+}
+
+func (s *RingpopSuite) TestBootstrapLatencyMetric() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	scope := tally.NewTestScope("", nil)
+	f, err := NewFactory(&cfg, WithMetrics(scope))
+	s.Nil(err)
+
+	f.recordBootstrapLatency(300*time.Millisecond, nil)
+	f.recordBootstrapLatency(2*time.Second, errors.New("join failed"))
+	f.recordBootstrapLatency(time.Second, nil)
+
+	counts := make(map[string]int64)
+	for _, h := range scope.Snapshot().Histograms() {
+		s.Equal(bootstrapLatencyMetric, h.Name())
+		s.Equal("hosts", h.Tags()[bootstrapModeTag])
+		for _, count := range h.Durations() {
+			counts[h.Tags()[bootstrapResultTag]] += count
+		}
+	}
+	s.Equal(map[string]int64{bootstrapResultOK: 2, bootstrapResultError: 1}, counts)
+}
+
+func (s *RingpopSuite) TestMetricTags() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.MetricTags = map[string]string{"ring": ""}
+	s.NotNil(cfg.validate())
+	cfg.MetricTags = map[string]string{" ": "history"}
+	s.NotNil(cfg.validate())
+
+	cfg.MetricTags = map[string]string{"ring": "history", "region": "us-west"}
+	scope := tally.NewTestScope("", nil)
+	f, err := NewFactory(&cfg, WithMetrics(scope))
+	s.Nil(err)
+	f.recordBootstrapLatency(time.Second, nil)
+	histograms := scope.Snapshot().Histograms()
+	s.Len(histograms, 1)
+	for _, h := range histograms {
+		s.Equal("history", h.Tags()["ring"])
+		s.Equal("us-west", h.Tags()["region"])
+		s.Equal("hosts", h.Tags()[bootstrapModeTag])
+	}
+
+	effective := f.EffectiveConfig()
+	effective.MetricTags["ring"] = "matching"
+	s.Equal("history", cfg.MetricTags["ring"])
+}
+
+func (s *RingpopSuite) TestMembershipMetrics() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	scope := tally.NewTestScope("", nil)
+	f, err := NewFactory(&cfg, WithMetrics(scope), WithClock(newFakeClock()))
+	s.Nil(err)
+
+	count := 3
+	metrics := &membershipMetrics{factory: f, countMembers: func() (int, error) { return count, nil }}
+	metrics.HandleEvent(events.RingChangedEvent{ServersAdded: []string{"10.0.0.2:7933", "10.0.0.3:7933"}})
+	metrics.HandleEvent(events.RingChangedEvent{ServersRemoved: []string{"10.0.0.3:7933"}})
+	metrics.HandleEvent(swim.JoinCompleteEvent{NumJoined: 1})
+	snapshot := scope.Snapshot()
+	values := make(map[string]float64)
+	for _, c := range snapshot.Counters() {
+		values[c.Name()] = float64(c.Value())
+	}
+	for _, g := range snapshot.Gauges() {
+		values[g.Name()] = g.Value()
+	}
+	s.Equal(map[string]float64{membersJoinedMetric: 2, membersLeftMetric: 1, membersMetric: 3}, values)
+
+	// a sampled gauge isn't updated on changes
+	cfg.MetricsSampleInterval = time.Minute
+	count = 2
+	metrics.HandleEvent(events.RingChangedEvent{ServersRemoved: []string{"10.0.0.2:7933"}})
+	for _, g := range scope.Snapshot().Gauges() {
+		s.Equal(float64(3), g.Value())
+	}
+	f.startMembersSampler(metrics)
+	f.Stop()
+	for _, g := range scope.Snapshot().Gauges() {
+		s.Equal(float64(2), g.Value())
+	}
+
+	// changes handled concurrently leave the gauge with the latest count
+	cfg.MetricsSampleInterval = 0
+	calls := 0
+	metrics = &membershipMetrics{factory: f, countMembers: func() (int, error) {
+		calls++
+		return calls, nil
+	}}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			metrics.HandleEvent(events.RingChangedEvent{ServersAdded: []string{"10.0.0.2:7933"}})
+		}()
+	}
+	wg.Wait()
+	for _, g := range scope.Snapshot().Gauges() {
+		s.Equal(float64(10), g.Value())
+	}
+
+	cfg.MetricsSampleInterval = -time.Minute
+	s.NotNil(cfg.validate())
+}
+
+func (s *RingpopSuite) TestMaintenance() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+	_, err = f.Members(ExcludeMaintenance())
+	s.Equal(ErrRingpopNotCreated, err)
+
+	// the state is kept until the ring is joined
+	s.Nil(f.SetMaintenance(true))
+	s.True(f.maintenance)
+	s.Nil(f.SetMaintenance(false))
+	s.False(f.maintenance)
+
+	var opts membersOptions
+	ExcludeMaintenance()(&opts)
+	s.Len(opts.predicates, 1)
+	s.True(opts.predicates[0](swim.Member{Address: "10.0.0.1:7933"}))
+	s.True(opts.predicates[0](swim.Member{Address: "10.0.0.1:7933", Labels: map[string]string{MaintenanceLabel: "false"}}))
+	s.False(opts.predicates[0](swim.Member{Address: "10.0.0.1:7933", Labels: map[string]string{MaintenanceLabel: "true"}}))
+}
+
+func (s *RingpopSuite) TestAdvertiseConflicts() {
+	testCases := []struct {
+		address       string
+		port          int
+		autoIncrement int
+		conflicts     []string
+	}{
+		{address: "10.0.0.9", port: 17933},
+		{address: "10.0.0.9:7933"},
+		{address: "10.0.0.9", autoIncrement: 10},
+		{autoIncrement: 10},
+		{address: "10.0.0.9:7933", port: 17933, conflicts: []string{"`advertiseAddress` has a port and `advertisePort` is set"}},
+		{port: 17933, autoIncrement: 10, conflicts: []string{"`advertisePort` and `portAutoIncrement` are both set"}},
+		{address: "10.0.0.9:7933", autoIncrement: 10, conflicts: []string{"`advertiseAddress` has a port and `portAutoIncrement` is set"}},
+		{address: "10.0.0.9:7933", port: 17933, autoIncrement: 10, conflicts: []string{
+			"`advertiseAddress` has a port and `advertisePort` is set",
+			"`advertisePort` and `portAutoIncrement` are both set",
+			"`advertiseAddress` has a port and `portAutoIncrement` is set",
+		}},
+	}
+	for _, tc := range testCases {
+		var cfg Ringpop
+		err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+		s.Nil(err)
+		cfg.AdvertiseAddress = tc.address
+		cfg.AdvertisePort = tc.port
+		cfg.PortAutoIncrement = tc.autoIncrement
+		err = cfg.validate()
+		if len(tc.conflicts) == 0 {
+			s.Nil(err)
+			continue
+		}
+		s.NotNil(err)
+		for _, conflict := range tc.conflicts {
+			s.Contains(err.Error(), conflict)
+		}
+		s.Contains(err.Error(), advertisePrecedence)
+	}
+}
+
+func (s *RingpopSuite) TestLeaveGracePeriod() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	clock := &firingClock{}
+	f, err := NewFactory(&cfg, WithClock(clock))
+	s.Nil(err)
+	s.Equal(defaultLeaveGracePeriod, cfg.LeaveGracePeriod)
+
+	evicted := 0
+	f.leave(func() error {
+		evicted++
+		return nil
+	})
+	s.Equal(1, evicted)
+	s.Equal([]time.Duration{defaultLeaveGracePeriod}, clock.waits())
+
+	// no grace period when the leave fails
+	f.leave(func() error {
+		return errors.New("not ready")
+	})
+	s.Len(clock.waits(), 1)
+
+	cfg.LeaveGracePeriod = -time.Second
+	s.NotNil(cfg.validate())
+}
+
+func (s *RingpopSuite) TestDetectDuplicateName() {
+	newFactory := func() *RingpopFactory {
+		var cfg Ringpop
+		err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+		s.Nil(err)
+		cfg.Name = "duplicate-name-test"
+		cfg.DetectDuplicateName = true
+		f, err := cfg.NewFactory()
+		s.Nil(err)
+		return f
+	}
+	f1, f2 := newFactory(), newFactory()
+	s.Nil(f1.registerName())
+	s.Nil(f1.registerName())
+	s.NotNil(f2.registerName())
+
+	f1.Destroy()
+	s.Nil(f2.registerName())
+	f2.releaseName()
+
+	f2.config.DetectDuplicateName = false
+	s.Nil(f1.registerName())
+	s.Nil(f2.registerName())
+	f1.releaseName()
+}
+
+func (s *RingpopSuite) TestGzipBootstrapFile() {
+	file, err := ioutil.TempFile("", "ringpop-bootstrap.json.gz")
+	s.Nil(err)
+	defer os.Remove(file.Name())
+	writer := gzip.NewWriter(file)
+	_, err = writer.Write([]byte(`["10.0.0.1:7933", {"address": "10.0.0.2:7933"}]`))
+	s.Nil(err)
+	s.Nil(writer.Close())
+	s.Nil(file.Close())
+
+	hosts, err := newFileProvider(file.Name(), "").Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933", "10.0.0.2:7933"}, hosts)
+
+	s.Nil(ioutil.WriteFile(file.Name(), []byte{0x1f, 0x8b, 0x00, 0x01}, 0644))
+	_, err = newFileProvider(file.Name(), "").Hosts()
+	s.NotNil(err)
+	s.Contains(err.Error(), "unable to decompress")
+	s.Contains(err.Error(), file.Name())
+}
+
+func (s *RingpopSuite) TestBootstrapAttemptID() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+	s.Empty(f.BootstrapAttemptID())
+	s.Equal(f.logger, f.bootstrapLogger())
+
+	id := f.startBootstrapAttempt(context.Background())
+	s.NotEmpty(id)
+	s.Equal(id, f.BootstrapAttemptID())
+	s.NotEqual(id, f.startBootstrapAttempt(context.Background()))
+	f.endBootstrapAttempt()
+	s.Equal(f.logger, f.bootstrapLogger())
+
+	ctx := WithBootstrapAttemptID(context.Background(), "trace-1234")
+	s.Equal("trace-1234", f.startBootstrapAttempt(ctx))
+	f.endBootstrapAttempt()
+	s.Equal("trace-1234", f.BootstrapAttemptID())
+}
+
+func (s *RingpopSuite) TestSeedSnapshot() {
+	dir, err := ioutil.TempDir("", "ringpop-snapshot")
+	s.Nil(err)
+	defer os.RemoveAll(dir)
+
+	var cfg Ringpop
+	err = yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.SeedSnapshotFile = filepath.Join(dir, "seeds.json")
+	cfg.SeedSnapshotMinHosts = 2
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+	s.Equal(defaultSeedSnapshotInterval, cfg.SeedSnapshotInterval)
+
+	// a missing snapshot leaves the primary result as is
+	scope := tally.NewTestScope("", nil)
+	primary := &snapshotFallbackProvider{
+		primary:  statichosts.New("10.0.0.1:7933"),
+		path:     cfg.SeedSnapshotFile,
+		minHosts: 2,
+		logger:   f.logger,
+		scope:    scope,
+	}
+	hosts, err := primary.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933"}, hosts)
+
+	s.Nil(f.writeSeedSnapshot(func() ([]string, error) {
+		return []string{"10.0.0.1:7933", "10.0.0.2:7933", "10.0.0.3:7933"}, nil
+	}))
+	hosts, err = primary.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933", "10.0.0.2:7933", "10.0.0.3:7933"}, hosts)
+
+	primary.primary = statichosts.New("10.0.0.4:7933", "10.0.0.5:7933")
+	hosts, err = primary.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.4:7933", "10.0.0.5:7933"}, hosts)
+
+	// only the bootstrap served by the snapshot is counted
+	counters := scope.Snapshot().Counters()
+	s.Len(counters, 1)
+	for _, counter := range counters {
+		s.Equal(bootstrapFallbackMetric, counter.Name())
+		s.Equal(snapshotSource, counter.Tags()[bootstrapSourceTag])
+		s.Equal(int64(1), counter.Value())
+	}
+
+	// a corrupt snapshot is ignored
+	s.Nil(ioutil.WriteFile(cfg.SeedSnapshotFile, []byte("{"), 0644))
+	primary.primary = statichosts.New()
+	hosts, err = primary.Hosts()
+	s.Nil(err)
+	s.Empty(hosts)
+
+	s.IsType(&snapshotFallbackProvider{}, f.withSnapshotFallback(statichosts.New()))
+	cfg.SeedSnapshotInterval = -time.Second
+	s.NotNil(cfg.validate())
+}
+
+func (s *RingpopSuite) TestLookup() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+	_, err = f.Lookup("workflow-id")
+	s.Equal(ErrRingpopNotCreated, err)
+	_, err = f.LookupN("workflow-id", 3)
+	s.Equal(ErrRingpopNotCreated, err)
+	_, err = f.LookupN("workflow-id", 0)
+	s.NotNil(err)
+}
+
+func (s *RingpopSuite) TestRingChange() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+
+	var changes []RingChange
+	f.OnRingChange(func(change RingChange) { changes = append(changes, change) })
+	listener := &ringChangeListener{factory: f}
+	listener.HandleEvent(events.RingChangedEvent{
+		ServersAdded:   []string{"10.0.0.2:7933"},
+		ServersRemoved: []string{"10.0.0.3:7933"},
+	})
+	listener.HandleEvent(swim.JoinCompleteEvent{NumJoined: 1})
+	s.Equal([]RingChange{{Added: []string{"10.0.0.2:7933"}, Removed: []string{"10.0.0.3:7933"}}}, changes)
+}
+
+func (s *RingpopSuite) TestPrepareLeave() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+	_, err = f.PrepareLeave()
+	s.Equal(ErrRingpopNotCreated, err)
+
+	self := "10.0.0.1:7933"
+	members := []string{self, "10.0.0.2:7933", "10.0.0.3:7933"}
+	handoff := handoffRanges(self, members, 10)
+	s.NotContains(handoff, self)
+	ranges := 0
+	for owner, owned := range handoff {
+		s.Contains(members, owner)
+		ranges += len(owned)
+	}
+	s.Equal(10, ranges)
+
+	// the new owner of each range is the member of the next token
+	tokens := ringTokens(members, 10)
+	for i, token := range tokens {
+		if token.member != self {
+			continue
+		}
+		next := tokens[(i+1)%len(tokens)]
+		if next.member != self {
+			prev := tokens[(i+len(tokens)-1)%len(tokens)].hash
+			s.Contains(handoff[next.member], fmt.Sprintf("(%v,%v]", prev, token.hash))
+		}
+	}
+
+	s.Empty(handoffRanges(self, []string{self}, 10))
+}
+
+func (s *RingpopSuite) TestWaitUntilDrained() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.HashRingReplicaPoints = 10
+	f, err := NewFactory(&cfg, WithClock(&firingClock{}))
+	s.Nil(err)
+	s.Equal(ErrRingpopNotCreated, f.Leave())
+	s.Equal(ErrRingpopNotCreated, f.WaitUntilDrained(context.Background()))
+
+	self := "10.0.0.1:7933"
+	polls := 0
+	getMembers := func() ([]string, error) {
+		polls++
+		if polls < 3 {
+			return []string{self, "10.0.0.2:7933"}, nil
+		}
+		return []string{"10.0.0.2:7933"}, nil
+	}
+	s.Nil(f.waitUntilDrained(context.Background(), self, getMembers))
+	s.Equal(3, polls)
+
+	f.clock = newFakeClock()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = f.waitUntilDrained(ctx, self, func() ([]string, error) {
+		return []string{self, "10.0.0.2:7933"}, nil
+	})
+	s.IsType(&DrainError{}, err)
+	s.Equal(10, err.(*DrainError).OwnedRanges)
+	s.Equal(context.Canceled, err.(*DrainError).Err)
+}
+
+func (s *RingpopSuite) TestMembersMatching() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+	_, err = f.MembersMatching(func(map[string]string) bool { return true })
+	s.Equal(ErrRingpopNotCreated, err)
+	s.Nil(f.Channel())
+
+	pred := labelPredicate(func(labels map[string]string) bool {
+		match := labels[partitionLabel] == "blue"
+		labels[partitionLabel] = "green"
+		return match
+	})
+	member := swim.Member{Address: "10.0.0.1:7933", Labels: map[string]string{partitionLabel: "blue"}}
+	s.True(pred(member))
+	s.Equal("blue", member.Labels[partitionLabel])
+	s.False(pred(swim.Member{Address: "10.0.0.2:7933"}))
+}
+
+// flakyProvider returns its hosts unless told to fail
+type flakyProvider struct {
+	hosts []string
+	fail  bool
+	calls int
+}
+
+func (p *flakyProvider) Hosts() ([]string, error) {
+	p.calls++
+	if p.fail {
+		return nil, errors.New("discovery failed")
+	}
+	return p.hosts, nil
+}
+
+type recordingKey struct{}
+
+// recordingParentKey holds the name of the span of a context
+var recordingParentKey = recordingKey{}
+
+// recordingTracer records the spans once they end
+type recordingTracer struct {
+	spans []*recordingSpan
+}
+
+type recordingSpan struct {
+	tracer     *recordingTracer
+	name       string
+	parent     interface{}
+	attributes map[string]interface{}
+	err        error
+}
+
+func (t *recordingTracer) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	span := &recordingSpan{tracer: t, name: name, parent: ctx.Value(recordingParentKey), attributes: map[string]interface{}{}}
+	return context.WithValue(ctx, recordingParentKey, name), span
+}
+
+func (span *recordingSpan) SetAttribute(key string, value interface{}) {
+	span.attributes[key] = value
+}
+
+func (span *recordingSpan) End(err error) {
+	span.err = err
+	span.tracer.spans = append(span.tracer.spans, span)
+}
+
+// growingProvider returns the next batch of hosts on each call,
+// and the last batch once they are exhausted
+type growingProvider struct {
+	batches [][]string
+	calls   int
+}
+
+func (p *growingProvider) Hosts() ([]string, error) {
+	index := p.calls
+	if index >= len(p.batches) {
+		index = len(p.batches) - 1
+	}
+	p.calls++
+	return p.batches[index], nil
+}
+
+func (s *RingpopSuite) TestDiscoveryBreaker() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.DiscoveryBreakerThreshold = 2
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	scope := tally.NewTestScope("", nil)
+	f, err := NewFactory(&cfg, WithClock(clock), WithMetrics(scope))
+	s.Nil(err)
+	s.Equal(defaultDiscoveryBreakerCooldown, cfg.DiscoveryBreakerCooldown)
+	provider, err := f.discoveryProvider()
+	s.Nil(err)
+	s.IsType(&breakerProvider{}, provider)
+
+	source := &flakyProvider{hosts: []string{"10.0.0.1:7933"}}
+	breaker := &breakerProvider{provider: source, breaker: f.discoveryBreaker}
+	hosts, err := breaker.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933"}, hosts)
+
+	// the breaker opens on the second consecutive failure
+	source.fail = true
+	_, err = breaker.Hosts()
+	s.NotNil(err)
+	hosts, err = breaker.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933"}, hosts)
+	s.Equal(3, source.calls)
+
+	// the last good seeds are served without discovery during the cooldown
+	clock.advance(10 * time.Second)
+	hosts, err = breaker.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.1:7933"}, hosts)
+	s.Equal(3, source.calls)
+
+	// a failed probe cools down again, a successful one closes the breaker
+	clock.advance(30 * time.Second)
+	_, err = breaker.Hosts()
+	s.Nil(err)
+	s.Equal(4, source.calls)
+	_, err = breaker.Hosts()
+	s.Equal(4, source.calls)
+	clock.advance(30 * time.Second)
+	source.fail = false
+	source.hosts = []string{"10.0.0.2:7933"}
+	hosts, err = breaker.Hosts()
+	s.Nil(err)
+	s.Equal([]string{"10.0.0.2:7933"}, hosts)
+	s.Equal(5, source.calls)
+
+	counts := make(map[string]int64)
+	for _, c := range scope.Snapshot().Counters() {
+		counts[c.Name()] = c.Value()
+	}
+	s.Equal(map[string]int64{discoveryBreakerOpenedMetric: 1, discoveryBreakerClosedMetric: 1}, counts)
+
+	// without last good seeds, failures are returned as is
+	f.discoveryBreaker.lastGood = nil
+	source.fail = true
+	for i := 0; i < 3; i++ {
+		_, err = breaker.Hosts()
+		s.NotNil(err)
+	}
+
+	cfg.DiscoveryBreakerCooldown = -time.Second
+	s.NotNil(cfg.validate())
+}
+
+func (s *RingpopSuite) TestDerivePortFromChannel() {
+	port, err := channelPort("10.0.0.1:7933")
+	s.Nil(err)
+	s.Equal("7933", port)
+	_, err = channelPort("0.0.0.0:0")
+	s.NotNil(err)
+	s.Contains(err.Error(), "wildcard port")
+	_, err = channelPort("10.0.0.1")
+	s.NotNil(err)
+
+	hosts := derivePorts([]string{"10.0.0.1", "10.0.0.2:7934", "cadence.example.com", "2001:db8::1", "[2001:db8::2]", "[2001:db8::3]:7934"}, port)
+	s.Equal([]string{
+		"10.0.0.1:7933",
+		"10.0.0.2:7934",
+		"cadence.example.com:7933",
+		"[2001:db8::1]:7933",
+		"[2001:db8::2]:7933",
+		"[2001:db8::3]:7934",
+	}, hosts)
+}
+
+func (s *RingpopSuite) TestDiffMembership() {
+	a := []string{"10.0.0.1:7933", "[::ffff:10.0.0.2]:7933", "[2001:DB8::1]:07933", "Cadence-A:7933", "10.0.0.5:7933", "10.0.0.5:7933"}
+	b := []string{"10.0.0.2:7933", "[2001:db8:0::1]:7933", "cadence-a:7933", "10.0.0.1:7934", "10.0.0.6:7933"}
+	onlyA, onlyB := DiffMembership(a, b)
+	s.Equal([]string{"10.0.0.1:7933", "10.0.0.5:7933"}, onlyA)
+	s.Equal([]string{"10.0.0.1:7934", "10.0.0.6:7933"}, onlyB)
+
+	onlyA, onlyB = DiffMembership(a, a)
+	s.Empty(onlyA)
+	s.Empty(onlyB)
+
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	f, err := cfg.NewFactory()
+	s.Nil(err)
+	_, _, err = f.CompareWith(b)
+	s.Equal(ErrRingpopNotCreated, err)
+}
+
+func (s *RingpopSuite) TestOnMembershipCross() {
+	var cfg Ringpop
+	err := yaml.Unmarshal([]byte(getHostsConfig()), &cfg)
+	s.Nil(err)
+	cfg.MembershipCrossDebounce = -time.Second
+	s.NotNil(cfg.validate())
+	cfg.MembershipCrossDebounce = 0
+	f, err := NewFactory(&cfg, WithClock(&firingClock{}))
+	s.Nil(err)
+	s.NotNil(f.OnMembershipCross(0, DirectionUp, func(int) {}))
+	s.NotNil(f.OnMembershipCross(3, Direction(0), func(int) {}))
+
+	var mutex sync.Mutex
+	var calls []string
+	record := func(name string) func(int) {
+		return func(count int) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			calls = append(calls, fmt.Sprintf("%v:%v", name, count))
+		}
+	}
+	s.Nil(f.OnMembershipCross(3, DirectionUp, record("up3")))
+	s.Nil(f.OnMembershipCross(3, DirectionDown, record("down3")))
+	s.Nil(f.OnMembershipCross(5, DirectionBoth, record("both5")))
+
+	count := 0
+	countMembers := func() (int, error) { return count, nil }
+	for _, count = range []int{1, 3, 4, 6, 5, 2, 2, 7} {
+		f.checkMembershipCrossings(count, countMembers)
+	}
+	s.Equal([]string{"up3:3", "both5:6", "down3:2", "both5:2", "up3:7", "both5:7"}, calls)
+
+	// with a debounce, the crossing is only reported when the count holds
+	cfg.MembershipCrossDebounce = time.Second
+	calls = nil
+	count = 2
+	f.checkMembershipCrossings(2, countMembers)
+	f.shutdownWG.Wait()
+	sort.Strings(calls)
+	s.Equal([]string{"both5:2", "down3:2"}, calls)
+
+	calls = nil
+	count = 2
+	f.checkMembershipCrossings(4, countMembers)
+	f.shutdownWG.Wait()
+	s.Empty(calls)
+}
+
+// fakeSwim answers the admin stats of self and records the swim
+// pings it answers, the members that are down answer nothing and
+// nothing is answered once the context of the call is done
+type fakeSwim struct {
+	members []string
+	down    map[string]bool
+	pinged  []string
+	pings   []*swimPing
+}
+
+func newFakeSwim(members ...string) *fakeSwim {
+	return &fakeSwim{members: members, down: make(map[string]bool)}
+}
+
+func (r *fakeSwim) call(ctx context.Context, hostPort string, method string, arg interface{}, resp interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if r.down[hostPort] {
+		return fmt.Errorf("%v is unreachable", hostPort)
+	}
+	switch method {
+	case ringpopAdminStatsEndpoint:
+		stats := resp.(*peerStats)
+		stats.Membership.Checksum = 1234
+		for _, member := range r.members {
+			stats.Membership.Members = append(stats.Membership.Members, peerMember{
+				Address:     member,
+				Status:      swim.Alive,
+				Incarnation: 42,
+				Labels:      map[string]string{serviceNameLabel: "cadence-history"},
+			})
+		}
+	case swimPingEndpoint:
+		ping := arg.(*swimPing)
+		r.pinged = append(r.pinged, hostPort)
+		r.pings = append(r.pings, ping)
+		*resp.(*swimPing) = swimPing{Source: hostPort, Checksum: ping.Checksum}
+	default:
+		return fmt.Errorf("unexpected call of %v", method)
+	}
+	return nil
+}
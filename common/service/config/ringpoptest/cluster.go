@@ -0,0 +1,177 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package ringpoptest spins up rings of in-process nodes for tests
+package ringpoptest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/uber/cadence/common/service/config"
+	"github.com/uber/ringpop-go"
+	tcg "github.com/uber/tchannel-go"
+)
+
+const (
+	serviceName = "ringpoptest"
+	joinTimeout = 10 * time.Second
+)
+
+type (
+	// Cluster is a ring of in-process nodes gossiping over loopback tchannels
+	Cluster struct {
+		nodes []*Node
+	}
+
+	// Node is a member of the cluster
+	Node struct {
+		// Address is the host:port of the node on the ring
+		Address string
+		// Factory is the factory that joined the node to the ring
+		Factory *config.RingpopFactory
+		// Ringpop is the ringpop instance of the node
+		Ringpop *ringpop.Ringpop
+
+		channel *tcg.Channel
+	}
+)
+
+// NewCluster creates a ring of n nodes and returns once every node sees
+// all of them as members. Tchannel has no in-memory transport, so each
+// node listens on an ephemeral port of the loopback interface
+func NewCluster(n int) (*Cluster, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("ringpoptest cluster of %v nodes, n must be positive", n)
+	}
+	cluster := &Cluster{}
+	var addresses []string
+	for i := 0; i < n; i++ {
+		ch, err := tcg.NewChannel(serviceName, nil)
+		if err != nil {
+			cluster.Stop()
+			return nil, err
+		}
+		if err := ch.ListenAndServe("127.0.0.1:0"); err != nil {
+			ch.Close()
+			cluster.Stop()
+			return nil, err
+		}
+		address := ch.PeerInfo().HostPort
+		cluster.nodes = append(cluster.nodes, &Node{Address: address, channel: ch})
+		addresses = append(addresses, address)
+	}
+
+	for _, node := range cluster.nodes {
+		factory, err := config.NewFactory(&config.Ringpop{
+			Name:            serviceName,
+			BootstrapMode:   config.BootstrapModeHosts,
+			BootstrapHosts:  addresses,
+			MaxJoinDuration: joinTimeout,
+		})
+		if err != nil {
+			cluster.Stop()
+			return nil, err
+		}
+		node.Factory = factory
+	}
+
+	// the nodes join concurrently, since each join waits for its seeds
+	errC := make(chan error, n)
+	var wg sync.WaitGroup
+	for _, node := range cluster.nodes {
+		wg.Add(1)
+		go func(node *Node) {
+			defer wg.Done()
+			errC <- node.join()
+		}(node)
+	}
+	wg.Wait()
+	close(errC)
+	for err := range errC {
+		if err != nil {
+			cluster.Stop()
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), joinTimeout)
+	defer cancel()
+	for _, node := range cluster.nodes {
+		err := node.Factory.WaitForMembership(ctx, func(members []string) bool {
+			return len(members) == n
+		})
+		if err != nil {
+			cluster.Stop()
+			return nil, fmt.Errorf("ringpoptest node %v doesn't see the %v nodes of the cluster: %v", node.Address, n, err)
+		}
+	}
+	return cluster, nil
+}
+
+func (node *Node) join() error {
+	prepared, err := node.Factory.ResolveAndPrepare(node.channel)
+	if err != nil {
+		return err
+	}
+	rp, err := prepared.Join()
+	if err != nil {
+		return fmt.Errorf("ringpoptest node %v failed to join: %v", node.Address, err)
+	}
+	node.Ringpop = rp
+	return nil
+}
+
+// Nodes returns the nodes of the cluster
+func (cluster *Cluster) Nodes() []*Node {
+	return cluster.nodes
+}
+
+// Addresses returns the addresses of the nodes of the cluster
+func (cluster *Cluster) Addresses() []string {
+	addresses := make([]string, 0, len(cluster.nodes))
+	for _, node := range cluster.nodes {
+		addresses = append(addresses, node.Address)
+	}
+	return addresses
+}
+
+// Stop destroys the ringpop instances and closes the channels of the cluster
+func (cluster *Cluster) Stop() {
+	var wg sync.WaitGroup
+	for _, node := range cluster.nodes {
+		wg.Add(1)
+		go func(node *Node) {
+			defer wg.Done()
+			node.stop()
+		}(node)
+	}
+	wg.Wait()
+	cluster.nodes = nil
+}
+
+func (node *Node) stop() {
+	if node.Factory != nil {
+		node.Factory.Destroy()
+	}
+	node.channel.Close()
+}
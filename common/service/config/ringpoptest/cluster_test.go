@@ -0,0 +1,74 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpoptest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ClusterSuite struct {
+	*require.Assertions
+	suite.Suite
+}
+
+func TestClusterSuite(t *testing.T) {
+	suite.Run(t, new(ClusterSuite))
+}
+
+func (s *ClusterSuite) SetupTest() {
+	s.Assertions = require.New(s.T())
+}
+
+func (s *ClusterSuite) TestLookup() {
+	cluster, err := NewCluster(3)
+	s.Nil(err)
+	defer cluster.Stop()
+
+	// every node agrees on the owner of a key
+	owner, err := cluster.Nodes()[0].Factory.Lookup("workflow-id")
+	s.Nil(err)
+	s.Contains(cluster.Addresses(), owner)
+	for _, node := range cluster.Nodes()[1:] {
+		addr, err := node.Factory.Lookup("workflow-id")
+		s.Nil(err)
+		s.Equal(owner, addr)
+	}
+}
+
+func (s *ClusterSuite) TestChannel() {
+	cluster, err := NewCluster(2)
+	s.Nil(err)
+	defer cluster.Stop()
+
+	for _, node := range cluster.Nodes() {
+		ch := node.Factory.Channel()
+		s.NotNil(ch)
+		s.Equal(node.Address, ch.PeerInfo().HostPort)
+	}
+}
+
+func (s *ClusterSuite) TestInvalidSize() {
+	_, err := NewCluster(0)
+	s.NotNil(err)
+}